@@ -0,0 +1,391 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+type analyzeGraphCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	From     string `short:"f" long:"from" description:"Specify the start position of the period in yyyyMMdd format. Defaults to the date of the oldest pixel in the year up to --to."`
+	To       string `short:"t" long:"to" description:"Specify the end position of the period in yyyyMMdd format. Defaults to today in the graph's timezone."`
+	Goal     string `long:"goal" description:"Daily goal quantity. When specified, the goal attainment is reported."`
+	Window   int    `short:"w" long:"window" description:"The number of days used for the moving average." default:"7"`
+	Format   string `long:"format" description:"Output format." choice:"table" choice:"json" default:"table"`
+}
+
+type graphAnalysis struct {
+	GraphID       string           `json:"graphID"`
+	Unit          string           `json:"unit"`
+	Type          string           `json:"type"`
+	Timezone      string           `json:"timezone"`
+	From          string           `json:"from"`
+	To            string           `json:"to"`
+	Days          int              `json:"days"`
+	RecordedDays  int              `json:"recordedDays"`
+	Total         float64          `json:"total"`
+	DailyAverage  float64          `json:"dailyAverage"`
+	CurrentStreak int              `json:"currentStreak"`
+	LongestStreak streak           `json:"longestStreak"`
+	Weekdays      []periodAverage  `json:"weekdays"`
+	Months        []periodAverage  `json:"months"`
+	Percentiles   []percentile     `json:"percentiles"`
+	MovingAverage []datedValue     `json:"movingAverage"`
+	WeekOverWeek  weekOverWeek     `json:"weekOverWeek"`
+	Goal          *goalAttainment  `json:"goal,omitempty"`
+	graph         *graphDefinition `json:"-"`
+}
+
+type streak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type periodAverage struct {
+	Period  string  `json:"period"`
+	Total   float64 `json:"total"`
+	Average float64 `json:"average"`
+}
+
+type percentile struct {
+	Rank  int     `json:"rank"`
+	Value float64 `json:"value"`
+}
+
+type datedValue struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+type weekOverWeek struct {
+	ThisWeek float64  `json:"thisWeek"`
+	LastWeek float64  `json:"lastWeek"`
+	Change   *float64 `json:"changeRate,omitempty"`
+}
+
+type goalAttainment struct {
+	Quantity     float64 `json:"quantity"`
+	AchievedDays int     `json:"achievedDays"`
+	Rate         float64 `json:"rate"`
+}
+
+var analysisPercentileRanks = []int{25, 50, 75, 90, 100}
+
+func (aG *analyzeGraphCommand) Execute(args []string) error {
+	username, err := getUsername(aG.Username)
+	if err != nil {
+		return err
+	}
+
+	var goal *float64
+	if aG.Goal != "" {
+		g, err := strconv.ParseFloat(aG.Goal, 64)
+		if err != nil {
+			return fmt.Errorf("invalid goal `%s`", aG.Goal)
+		}
+		goal = &g
+	}
+
+	def, err := fetchGraphDefinition(username, aG.ID)
+	if err != nil {
+		return err
+	}
+	// the pixels are fetched by year, so that a period longer than a year is not cut short by the API.
+	from, to, err := graphPixelPeriod(def, aG.From, aG.To)
+	if err != nil {
+		return err
+	}
+	pixels, err := fetchGraphPixelsInWindows(username, aG.ID, from, to)
+	if err != nil {
+		return err
+	}
+
+	analysis, err := analyzePixels(def, pixels, aG.From, aG.To, aG.Window, goal)
+	if err != nil {
+		return err
+	}
+
+	if aG.Format == "json" {
+		return json.NewEncoder(os.Stdout).Encode(analysis)
+	}
+	printGraphAnalysis(os.Stdout, analysis)
+	return nil
+}
+
+// analyzePixels computes statistics over the calendar days between `from` and `to`.
+// Days without a pixel are treated as zero.
+func analyzePixels(def *graphDefinition, pixels []pixel, from string, to string, window int, goal *float64) (*graphAnalysis, error) {
+	if window < 1 {
+		return nil, fmt.Errorf("window must be greater than 0")
+	}
+
+	loc, err := def.location()
+	if err != nil {
+		return nil, err
+	}
+	quantities, err := pixelQuantities(pixels)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := def.today()
+	if err != nil {
+		return nil, err
+	}
+	if to != "" {
+		end, err = time.ParseInLocation(pixelDateLayout, to, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date `%s`. It must be specified in yyyyMMdd format", to)
+		}
+	}
+	start := end
+	if from != "" {
+		start, err = time.ParseInLocation(pixelDateLayout, from, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date `%s`. It must be specified in yyyyMMdd format", from)
+		}
+	} else if len(pixels) > 0 {
+		start, err = time.ParseInLocation(pixelDateLayout, pixels[0].Date, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pixel date `%s`", pixels[0].Date)
+		}
+	}
+	if start.After(end) {
+		return nil, fmt.Errorf("the start of the period must not be after the end")
+	}
+
+	dates := []time.Time{}
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d)
+	}
+
+	a := &graphAnalysis{
+		GraphID:  def.ID,
+		Unit:     def.Unit,
+		Type:     def.Type,
+		Timezone: loc.String(),
+		From:     start.Format(pixelDateLayout),
+		To:       end.Format(pixelDateLayout),
+		Days:     len(dates),
+		graph:    def,
+	}
+
+	values := make([]float64, len(dates))
+	recorded := []float64{}
+	weekdayTotals := make([]float64, 7)
+	weekdayDays := make([]int, 7)
+	months := []periodAverage{}
+	monthDays := []int{}
+	for i, d := range dates {
+		q, ok := quantities[d.Format(pixelDateLayout)]
+		values[i] = q
+		if ok {
+			recorded = append(recorded, q)
+		}
+		a.Total += q
+		weekdayTotals[d.Weekday()] += q
+		weekdayDays[d.Weekday()]++
+
+		month := d.Format("2006-01")
+		if len(months) == 0 || months[len(months)-1].Period != month {
+			months = append(months, periodAverage{Period: month})
+			monthDays = append(monthDays, 0)
+		}
+		months[len(months)-1].Total += q
+		monthDays[len(monthDays)-1]++
+	}
+	a.RecordedDays = len(recorded)
+	a.DailyAverage = a.Total / float64(len(dates))
+
+	// weeks start on Monday.
+	for i := 1; i <= 7; i++ {
+		wd := time.Weekday(i % 7)
+		avg := periodAverage{Period: wd.String(), Total: weekdayTotals[wd]}
+		if weekdayDays[wd] > 0 {
+			avg.Average = weekdayTotals[wd] / float64(weekdayDays[wd])
+		}
+		a.Weekdays = append(a.Weekdays, avg)
+	}
+	for i := range months {
+		months[i].Average = months[i].Total / float64(monthDays[i])
+	}
+	a.Months = months
+
+	a.CurrentStreak, a.LongestStreak = streaks(dates, values)
+	a.Percentiles = percentiles(recorded, analysisPercentileRanks)
+	a.MovingAverage = movingAverage(dates, values, window)
+	a.WeekOverWeek = compareWeeks(values)
+
+	if goal != nil {
+		g := &goalAttainment{Quantity: *goal}
+		for _, v := range values {
+			if v >= *goal {
+				g.AchievedDays++
+			}
+		}
+		g.Rate = float64(g.AchievedDays) / float64(len(values))
+		a.Goal = g
+	}
+
+	return a, nil
+}
+
+// streaks returns the current and the longest run of days with a positive quantity.
+// The current streak is not broken by the last day having no pixel yet.
+func streaks(dates []time.Time, values []float64) (int, streak) {
+	longest := streak{}
+	run := 0
+	for i, v := range values {
+		if v <= 0 {
+			run = 0
+			continue
+		}
+		run++
+		if run > longest.Days {
+			longest = streak{
+				Days:  run,
+				Start: dates[i-run+1].Format(pixelDateLayout),
+				End:   dates[i].Format(pixelDateLayout),
+			}
+		}
+	}
+
+	current := 0
+	i := len(values) - 1
+	if i >= 0 && values[i] <= 0 {
+		i--
+	}
+	for ; i >= 0 && values[i] > 0; i-- {
+		current++
+	}
+	return current, longest
+}
+
+// percentiles uses the nearest-rank method so that each result is an actual recorded quantity.
+func percentiles(recorded []float64, ranks []int) []percentile {
+	if len(recorded) == 0 {
+		return []percentile{}
+	}
+	sorted := append([]float64{}, recorded...)
+	sort.Float64s(sorted)
+
+	result := []percentile{}
+	for _, r := range ranks {
+		n := int(math.Ceil(float64(r) / 100 * float64(len(sorted))))
+		if n < 1 {
+			n = 1
+		}
+		result = append(result, percentile{Rank: r, Value: sorted[n-1]})
+	}
+	return result
+}
+
+// movingAverage returns the trailing average of `window` days for each day that has enough history.
+func movingAverage(dates []time.Time, values []float64, window int) []datedValue {
+	result := []datedValue{}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= window {
+			sum -= values[i-window]
+		}
+		if i >= window-1 {
+			result = append(result, datedValue{Date: dates[i].Format(pixelDateLayout), Value: sum / float64(window)})
+		}
+	}
+	return result
+}
+
+// compareWeeks compares the total of the last 7 days with the 7 days before them.
+func compareWeeks(values []float64) weekOverWeek {
+	w := weekOverWeek{}
+	for i := 0; i < 14 && i < len(values); i++ {
+		v := values[len(values)-1-i]
+		if i < 7 {
+			w.ThisWeek += v
+		} else {
+			w.LastWeek += v
+		}
+	}
+	if w.LastWeek != 0 {
+		change := (w.ThisWeek - w.LastWeek) / w.LastWeek
+		w.Change = &change
+	}
+	return w
+}
+
+func printGraphAnalysis(out io.Writer, a *graphAnalysis) {
+	quantity := a.graph.formatQuantity
+	average := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "graph\t%s (%s, %s)\n", a.GraphID, a.Type, a.Timezone)
+	fmt.Fprintf(w, "period\t%s - %s (%d days, %d recorded)\n", a.From, a.To, a.Days, a.RecordedDays)
+	fmt.Fprintf(w, "total\t%s %s\n", quantity(a.Total), a.Unit)
+	fmt.Fprintf(w, "daily average\t%s %s\n", average(a.DailyAverage), a.Unit)
+	fmt.Fprintf(w, "current streak\t%d days\n", a.CurrentStreak)
+	if a.LongestStreak.Days > 0 {
+		fmt.Fprintf(w, "longest streak\t%d days (%s - %s)\n", a.LongestStreak.Days, a.LongestStreak.Start, a.LongestStreak.End)
+	} else {
+		fmt.Fprintf(w, "longest streak\t0 days\n")
+	}
+	change := "-"
+	if a.WeekOverWeek.Change != nil {
+		change = fmt.Sprintf("%+.1f%%", *a.WeekOverWeek.Change*100)
+	}
+	fmt.Fprintf(w, "last 7 days\t%s (previous 7 days: %s, %s)\n", quantity(a.WeekOverWeek.ThisWeek), quantity(a.WeekOverWeek.LastWeek), change)
+	if a.Goal != nil {
+		fmt.Fprintf(w, "goal\t%d/%d days reached %s (%.1f%%)\n", a.Goal.AchievedDays, a.Days, quantity(a.Goal.Quantity), a.Goal.Rate*100)
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "percentile\tvalue")
+	for _, p := range a.Percentiles {
+		fmt.Fprintf(w, "p%d\t%s\n", p.Rank, quantity(p.Value))
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "weekday\ttotal\taverage")
+	for _, p := range a.Weekdays {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Period, quantity(p.Total), average(p.Average))
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "month\ttotal\taverage")
+	for _, p := range a.Months {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Period, quantity(p.Total), average(p.Average))
+	}
+	w.Flush()
+
+	if len(a.MovingAverage) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "date\tmoving average")
+	recent := a.MovingAverage
+	if len(recent) > 7 {
+		recent = recent[len(recent)-7:]
+	}
+	for _, v := range recent {
+		fmt.Fprintf(w, "%s\t%s\n", v.Date, average(v.Value))
+	}
+	w.Flush()
+}
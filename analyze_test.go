@@ -0,0 +1,202 @@
+package pi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var analyzeTests = []struct {
+	name     string
+	input    []string
+	exitCode int
+}{
+	{
+		name:     "analyze graph - not specify id",
+		input:    []string{"graphs", "analyze", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "analyze graph - not specify username",
+		input:    []string{"graphs", "analyze", "--graph-id", "test-id"},
+		exitCode: 1,
+	},
+	{
+		name:     "analyze graph - invalid format",
+		input:    []string{"graphs", "analyze", "--username", "c-know", "--graph-id", "test-id", "--format", "xml"},
+		exitCode: 1,
+	},
+}
+
+func TestAnalyze(t *testing.T) {
+	for _, tt := range analyzeTests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestAnalyzePixels(t *testing.T) {
+	def := &graphDefinition{ID: "test-id", Unit: "commits", Type: "int", Timezone: "Asia/Tokyo"}
+	// 2019-01-07 is Monday.
+	pixels := []pixel{
+		{Date: "20190101", Quantity: "2"},
+		{Date: "20190102", Quantity: "4"},
+		{Date: "20190103", Quantity: "6"},
+		{Date: "20190105", Quantity: "1"},
+		{Date: "20190107", Quantity: "3"},
+		{Date: "20190108", Quantity: "5"},
+		{Date: "20190109", Quantity: "0"},
+		{Date: "20190113", Quantity: "8"},
+		{Date: "20190114", Quantity: "1"},
+	}
+	goal := 3.0
+
+	a, err := analyzePixels(def, pixels, "", "20190115", 3, &goal)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+
+	if a.From != "20190101" || a.To != "20190115" || a.Days != 15 || a.RecordedDays != 9 {
+		t.Errorf("Unexpected period. %s - %s (%d, %d)", a.From, a.To, a.Days, a.RecordedDays)
+	}
+	if a.Timezone != "Asia/Tokyo" {
+		t.Errorf("Unexpected timezone. %s", a.Timezone)
+	}
+	if a.Total != 30 || a.DailyAverage != 2 {
+		t.Errorf("Unexpected total. %f %f", a.Total, a.DailyAverage)
+	}
+	if a.CurrentStreak != 2 {
+		t.Errorf("Unexpected current streak. %d", a.CurrentStreak)
+	}
+	if a.LongestStreak != (streak{Days: 3, Start: "20190101", End: "20190103"}) {
+		t.Errorf("Unexpected longest streak. %+v", a.LongestStreak)
+	}
+	if a.Weekdays[0].Period != "Monday" || a.Weekdays[0].Total != 4 || a.Weekdays[0].Average != 2 {
+		t.Errorf("Unexpected weekday average. %+v", a.Weekdays[0])
+	}
+	if len(a.Months) != 1 || a.Months[0].Period != "2019-01" || a.Months[0].Average != 2 {
+		t.Errorf("Unexpected month average. %+v", a.Months)
+	}
+	wantPercentiles := []percentile{{25, 1}, {50, 3}, {75, 5}, {90, 8}, {100, 8}}
+	for i, p := range wantPercentiles {
+		if a.Percentiles[i] != p {
+			t.Errorf("Unexpected percentile. got=%+v want=%+v", a.Percentiles[i], p)
+		}
+	}
+	if len(a.MovingAverage) != 13 || a.MovingAverage[0] != (datedValue{Date: "20190103", Value: 4}) {
+		t.Errorf("Unexpected moving average. %+v", a.MovingAverage)
+	}
+	if a.WeekOverWeek.ThisWeek != 9 || a.WeekOverWeek.LastWeek != 19 {
+		t.Errorf("Unexpected week over week. %+v", a.WeekOverWeek)
+	}
+	if a.Goal == nil || a.Goal.AchievedDays != 5 {
+		t.Errorf("Unexpected goal attainment. %+v", a.Goal)
+	}
+
+	out := &bytes.Buffer{}
+	printGraphAnalysis(out, a)
+	if !strings.Contains(out.String(), "longest streak  3 days (20190101 - 20190103)") {
+		t.Errorf("Unexpected output. %s", out.String())
+	}
+}
+
+func TestAnalyzePixelsCurrentStreakWithoutToday(t *testing.T) {
+	def := &graphDefinition{ID: "test-id", Type: "float"}
+	pixels := []pixel{
+		{Date: "20190101", Quantity: "1.5"},
+		{Date: "20190102", Quantity: "0.5"},
+	}
+
+	a, err := analyzePixels(def, pixels, "20190101", "20190103", 7, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if a.CurrentStreak != 2 {
+		t.Errorf("Unexpected current streak. %d", a.CurrentStreak)
+	}
+	if len(a.MovingAverage) != 0 {
+		t.Errorf("Unexpected moving average. %+v", a.MovingAverage)
+	}
+	if a.Goal != nil {
+		t.Errorf("Unexpected goal attainment. %+v", a.Goal)
+	}
+}
+
+func TestAnalyzePixelsError(t *testing.T) {
+	def := &graphDefinition{ID: "test-id", Type: "int"}
+	tests := []struct {
+		name   string
+		pixels []pixel
+		from   string
+		to     string
+		window int
+	}{
+		{name: "invalid window", from: "20190101", to: "20190102", window: 0},
+		{name: "invalid from", from: "2019-01-01", to: "20190102", window: 7},
+		{name: "reversed period", from: "20190102", to: "20190101", window: 7},
+		{name: "invalid quantity", pixels: []pixel{{Date: "20190101", Quantity: "a"}}, to: "20190102", window: 7},
+	}
+
+	for _, tt := range tests {
+		if _, err := analyzePixels(def, tt.pixels, tt.from, tt.to, tt.window, nil); err == nil {
+			t.Errorf("%s: expected error but not occurred", tt.name)
+		}
+	}
+}
+
+func TestGraphPixelPeriod(t *testing.T) {
+	def := &graphDefinition{ID: "test-id", Type: "int"}
+	today, _ := def.today()
+	tests := []struct {
+		from, to         string
+		wantFrom, wantTo string
+		wantErr          bool
+	}{
+		{"", "", today.AddDate(0, 0, -364).Format(pixelDateLayout), today.Format(pixelDateLayout), false},
+		{"", "20191231", "20190101", "20191231", false},
+		{"20180101", "20191231", "20180101", "20191231", false},
+		{"20200101", "20191231", "", "", true},
+		{"", "2019-12-31", "", "", true},
+	}
+	for _, tt := range tests {
+		from, to, err := graphPixelPeriod(def, tt.from, tt.to)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s-%s: unexpected error. %v", tt.from, tt.to, err)
+			continue
+		}
+		if from != tt.wantFrom || to != tt.wantTo {
+			t.Errorf("%s-%s: out=%s-%s want=%s-%s", tt.from, tt.to, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
+
+func TestAnalyzeOverYears(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.addGraph("c-know", graphDefinition{ID: "test-id", Type: "int"})
+	pixela.setPixel("c-know/test-id", "20180105", pixelBody{Quantity: "1"})
+	pixela.setPixel("c-know/test-id", "20191230", pixelBody{Quantity: "1"})
+
+	exitCode := (&CLI{
+		ErrStream: ioutil.Discard,
+		OutStream: ioutil.Discard,
+	}).Run([]string{"graphs", "analyze", "-g", "test-id", "--from", "20180101", "--to", "20191231"})
+	if exitCode != 0 {
+		t.Fatalf("Unexpected exit code. %d", exitCode)
+	}
+	fetched := 0
+	for _, r := range pixela.requested() {
+		if strings.HasSuffix(r, "/pixels") {
+			fetched++
+		}
+	}
+	if fetched != 2 {
+		t.Errorf("the period over a year should be fetched by year. %v", pixela.requested())
+	}
+}
@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
 )
 
+// httpClient sends the api requests. It is replaced in tests.
+var httpClient = &http.Client{}
+
 func generateRequest(method string, path string, paramStruct interface{}) (*http.Request, error) {
 	apibase := os.Getenv("PIXELA_API_BASE")
 	if apibase == "" {
@@ -59,25 +63,81 @@ func generateRequestWithToken(method string, path string, paramStruct interface{
 	return req, err
 }
 
+// apiError is returned when Pixela responds with an error status.
+type apiError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	return e.Body
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
 func doRequest(req *http.Request) error {
+	b, err := doRequestAndGetBody(req)
+	if err != nil {
+		return err
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	fmt.Println(string(b))
+
+	return nil
+}
+
+// doRequestAndGetBody returns the cached response of GET request if available. See httpCache for details.
+// `--no-cache` bypasses the cache for GET requests only, so that the other requests still invalidate the cached responses.
+// The request is sent without cache if the cache can not be set up.
+func doRequestAndGetBody(req *http.Request) ([]byte, error) {
+	cache, err := newHTTPCache()
 	if err != nil {
-		return fmt.Errorf("Failed to request api : %s", err)
+		if !httpCacheDisabled {
+			log.Printf("warning: response cache is not used : %s", err)
+		}
+		return checkResponse(sendRequest(req))
+	}
+	if httpCacheDisabled && req.Method == "GET" {
+		return checkResponse(sendRequest(req))
+	}
+	return cache.do(req, sendRequest)
+}
+
+// sendRequest waits for the rate limit if configured. The request is sent without limit if the limiter can not be set up.
+func sendRequest(req *http.Request) (*http.Response, []byte, error) {
+	limiter, err := newRateLimiter()
+	if err != nil {
+		log.Printf("warning: rate limit is not applied : %s", err)
+	} else if limiter != nil {
+		err = limiter.wait()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to request api : %s", err)
 	}
 
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("Failed to get response body : %s", err)
+		return nil, nil, fmt.Errorf("Failed to get response body : %s", err)
 	}
 	defer resp.Body.Close()
 
+	return resp, b, nil
+}
+
+func checkResponse(resp *http.Response, b []byte, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
 	if resp.StatusCode > 299 {
-		return fmt.Errorf("%s", string(b))
+		return nil, &apiError{StatusCode: resp.StatusCode, Body: string(b)}
 	}
-
-	fmt.Println(string(b))
-
-	return nil
+	return b, nil
 }
@@ -0,0 +1,268 @@
+package pi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type cacheCommand struct {
+	Clear clearCacheCommand `description:"remove cached responses" command:"clear" subcommands-optional:"true"`
+	Stats cacheStatsCommand `description:"show statistics of cached responses" command:"stats" subcommands-optional:"true"`
+}
+
+type clearCacheCommand struct{}
+
+type cacheStatsCommand struct{}
+
+// httpCache keeps the responses of GET requests on disk. The entries are grouped by the token,
+// so that the responses for a user are never returned for another user.
+// The responses of requests without token are grouped by the user in the path, so that an update of the user clears them too.
+// The cache is disabled if the TTL is zero.
+type httpCache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+type httpCacheEntry struct {
+	URL          string    `json:"url"`
+	Stored       time.Time `json:"stored"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	Body         []byte    `json:"body"`
+}
+
+type httpCacheStats struct {
+	Entries int
+	Fresh   int
+	Bytes   int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+// httpCacheDisabled is set by `--no-cache` option.
+var httpCacheDisabled = false
+
+func (cC *clearCacheCommand) Execute(args []string) error {
+	cache, err := newHTTPCache()
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(cache.dir)
+	if err != nil {
+		return fmt.Errorf("Failed to clear cache : %s", err)
+	}
+	fmt.Printf("cleared %s\n", cache.dir)
+	return nil
+}
+
+func (cS *cacheStatsCommand) Execute(args []string) error {
+	cache, err := newHTTPCache()
+	if err != nil {
+		return err
+	}
+	stats, err := cache.stats()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("directory\t%s\n", cache.dir)
+	if cache.enabled() {
+		fmt.Printf("ttl\t%s\n", cache.ttl)
+	} else {
+		fmt.Printf("ttl\tdisabled\n")
+	}
+	fmt.Printf("entries\t%d (%d fresh)\n", stats.Entries, stats.Fresh)
+	fmt.Printf("size\t%d bytes\n", stats.Bytes)
+	if stats.Entries > 0 {
+		fmt.Printf("oldest\t%s\n", stats.Oldest.Format(time.RFC3339))
+		fmt.Printf("newest\t%s\n", stats.Newest.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// newHTTPCache reads the TTL from `PI_CACHE_TTL` environment variable or `cache.ttl` of the config file.
+// The cache is opt-in, so the TTL is zero unless it is specified.
+func newHTTPCache() (*httpCache, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(0)
+	value := config.Cache.TTL
+	if env := os.Getenv("PI_CACHE_TTL"); env != "" {
+		value = env
+	}
+	if value != "" {
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid cache ttl `%s`", value)
+		}
+	}
+	return &httpCache{dir: filepath.Join(dir, "http"), ttl: ttl, now: time.Now}, nil
+}
+
+func (c *httpCache) enabled() bool {
+	return c.ttl > 0
+}
+
+// do returns the fresh cached response of GET request, or revalidates the stale one with the validators of the response.
+// `Cache-Control: no-cache` header of the request forces the revalidation.
+// The other requests invalidate the cache of the user.
+func (c *httpCache) do(req *http.Request, send func(req *http.Request) (*http.Response, []byte, error)) ([]byte, error) {
+	if req.Method != "GET" || !c.enabled() {
+		b, err := checkResponse(send(req))
+		if err == nil && req.Method != "GET" {
+			c.invalidate(req)
+		}
+		return b, err
+	}
+
+	group := c.group(req)
+	path := filepath.Join(group, hashString(req.URL.String())+".json")
+	entry := c.load(path)
+	if entry != nil {
+		if c.now().Sub(entry.Stored) < c.ttl && req.Header.Get("Cache-Control") != "no-cache" {
+			return entry.Body, nil
+		}
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, b, err := send(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		entry.Stored = c.now()
+		c.save(path, entry)
+		return entry.Body, nil
+	}
+	b, err = checkResponse(resp, b, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.save(path, &httpCacheEntry{
+		URL:          req.URL.String(),
+		Stored:       c.now(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         b,
+	})
+	return b, nil
+}
+
+func (c *httpCache) group(req *http.Request) string {
+	token := req.Header.Get("X-USER-TOKEN")
+	if token == "" {
+		return c.publicGroup(req)
+	}
+	return filepath.Join(c.dir, hashString(token)[:16])
+}
+
+// publicGroup is the group of the requests without token, such as stats and SVG of the user in the path.
+func (c *httpCache) publicGroup(req *http.Request) string {
+	user := "-"
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) >= 3 && parts[0] == "v1" && parts[1] == "users" {
+		user = parts[2]
+	}
+	return filepath.Join(c.dir, "public", hashString(user)[:16])
+}
+
+// invalidate removes the responses which an update by `req` may change.
+// It is done even while the cache is disabled, so that enabling it again never returns stale responses.
+func (c *httpCache) invalidate(req *http.Request) {
+	os.RemoveAll(c.group(req))
+	os.RemoveAll(c.publicGroup(req))
+}
+
+// load returns nil if the entry does not exist or is broken.
+func (c *httpCache) load(path string) *httpCacheEntry {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	entry := &httpCacheEntry{}
+	if json.Unmarshal(b, entry) != nil {
+		return nil
+	}
+	return entry
+}
+
+// save ignores errors, since a response which can not be cached is still a valid response.
+// The entry is renamed into place so that concurrent processes never read a partial entry.
+func (c *httpCache) save(path string, entry *httpCacheEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(path), 0700) != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(b)
+	tmp.Close()
+	if err != nil || os.Rename(tmp.Name(), path) != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (c *httpCache) stats() (*httpCacheStats, error) {
+	stats := &httpCacheStats{}
+	err := filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		entry := c.load(path)
+		if entry == nil {
+			return nil
+		}
+		stats.Entries++
+		stats.Bytes += info.Size()
+		if c.now().Sub(entry.Stored) < c.ttl {
+			stats.Fresh++
+		}
+		if stats.Oldest.IsZero() || entry.Stored.Before(stats.Oldest) {
+			stats.Oldest = entry.Stored
+		}
+		if entry.Stored.After(stats.Newest) {
+			stats.Newest = entry.Stored
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read cache : %s", err)
+	}
+	return stats, nil
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
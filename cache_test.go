@@ -0,0 +1,206 @@
+package pi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-cache")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	beforeCacheDir := os.Getenv("PI_CACHE_DIR")
+	os.Setenv("PI_CACHE_DIR", dir)
+	defer os.Setenv("PI_CACHE_DIR", beforeCacheDir)
+	beforeTTL := os.Getenv("PI_CACHE_TTL")
+	defer os.Setenv("PI_CACHE_TTL", beforeTTL)
+
+	tests := []struct {
+		name     string
+		ttl      string
+		input    []string
+		exitCode int
+	}{
+		{"cache stats", "", []string{"cache", "stats"}, 0},
+		{"cache clear", "", []string{"cache", "clear"}, 0},
+		{"cache stats - invalid ttl", "1 minute", []string{"cache", "stats"}, 1},
+		{"no-cache option", "", []string{"--no-cache", "version"}, 0},
+	}
+	for _, tt := range tests {
+		os.Setenv("PI_CACHE_TTL", tt.ttl)
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestHTTPCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-cache")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := &httpCache{dir: dir, ttl: time.Minute, now: func() time.Time { return now }}
+
+	sent := []string{}
+	status := http.StatusOK
+	body := "v1"
+	send := func(req *http.Request) (*http.Response, []byte, error) {
+		sent = append(sent, strings.TrimSpace(fmt.Sprintf("%s %s %s", req.Method, req.URL.Path, req.Header.Get("If-None-Match"))))
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		resp.Header.Set("ETag", `"`+body+`"`)
+		return resp, []byte(body), nil
+	}
+	get := func(token string) string {
+		req, _ := http.NewRequest("GET", "https://pixela.example.com/v1/users/c-know/graphs", nil)
+		if token != "" {
+			req.Header.Set("X-USER-TOKEN", token)
+		}
+		b, err := cache.do(req, send)
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		return string(b)
+	}
+
+	steps := []struct {
+		name  string
+		run   func() string
+		want  string
+		sends int
+	}{
+		{"first request", func() string { return get("secret") }, "v1", 1},
+		{"fresh entry", func() string { return get("secret") }, "v1", 1},
+		{"other token", func() string { return get("other") }, "v1", 2},
+		{"not modified", func() string {
+			now = now.Add(time.Minute)
+			status, body = http.StatusNotModified, ""
+			return get("secret")
+		}, "v1", 3},
+		{"revalidated entry is fresh", func() string { return get("secret") }, "v1", 3},
+		{"modified", func() string {
+			now = now.Add(time.Minute)
+			status, body = http.StatusOK, "v2"
+			return get("secret")
+		}, "v2", 4},
+		{"mutation invalidates the user", func() string {
+			req, _ := http.NewRequest("PUT", "https://pixela.example.com/v1/users/c-know/graphs/test-id", nil)
+			req.Header.Set("X-USER-TOKEN", "secret")
+			cache.do(req, send)
+			body = "v3"
+			return get("secret")
+		}, "v3", 6},
+		{"error is not cached", func() string {
+			now = now.Add(time.Minute)
+			status, body = http.StatusServiceUnavailable, "unavailable"
+			get("secret")
+			status, body = http.StatusOK, "v4"
+			return get("secret")
+		}, "v4", 8},
+	}
+	for _, s := range steps {
+		if got := s.run(); got != s.want {
+			t.Errorf("%s: body=%s want=%s", s.name, got, s.want)
+		}
+		if len(sent) != s.sends {
+			t.Errorf("%s: sent=%v", s.name, sent)
+		}
+	}
+	if sent[1] != `GET /v1/users/c-know/graphs` || sent[2] != `GET /v1/users/c-know/graphs "v1"` {
+		t.Errorf("Unexpected validators. %v", sent)
+	}
+
+	req, _ := http.NewRequest("GET", "https://pixela.example.com/v1/users/c-know/graphs", nil)
+	req.Header.Set("X-USER-TOKEN", "secret")
+	req.Header.Set("Cache-Control", "no-cache")
+	cache.do(req, send)
+	if len(sent) != 9 {
+		t.Errorf("no-cache request should be sent. %v", sent)
+	}
+
+	stats, err := cache.stats()
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if stats.Entries != 2 || stats.Fresh != 1 {
+		t.Errorf("Unexpected stats. %+v", stats)
+	}
+
+	publicStats := func() string {
+		req, _ := http.NewRequest("GET", "https://pixela.example.com/v1/users/c-know/graphs/test-id/stats", nil)
+		b, _ := cache.do(req, send)
+		return string(b)
+	}
+	status, body = http.StatusOK, "v5"
+	publicStats()
+	body = "v6"
+	if got := publicStats(); got != "v5" {
+		t.Errorf("public entry should be cached. %s", got)
+	}
+	req, _ = http.NewRequest("PUT", "https://pixela.example.com/v1/users/c-know/graphs/test-id", nil)
+	req.Header.Set("X-USER-TOKEN", "secret")
+	cache.do(req, send)
+	if got := publicStats(); got != "v6" {
+		t.Errorf("public entries of the user should be invalidated. %s", got)
+	}
+
+	disabled := &httpCache{dir: dir, now: cache.now}
+	body = "v7"
+	req, _ = http.NewRequest("GET", "https://pixela.example.com/v1/users/c-know/graphs/test-id/stats", nil)
+	if b, _ := disabled.do(req, send); string(b) != "v7" {
+		t.Errorf("disabled cache should not be used. %s", b)
+	}
+}
+
+func TestDoRequestAndGetBodyWithoutCache(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.addGraph("c-know", graphDefinition{ID: "test-id", Type: "int"})
+	os.Setenv("PI_CACHE_TTL", "1h")
+	httpCacheDisabled = false
+	defer func() { httpCacheDisabled = false }()
+
+	getPixels := func() int {
+		req, _ := generateGetGraphPixelsRequest(&getGraphPixelsCommand{ID: "test-id", WithBody: true})
+		b, err := doRequestAndGetBody(req)
+		if err != nil {
+			t.Fatalf("Unexpected error occurs. %s", err)
+		}
+		return strings.Count(string(b), `"date"`)
+	}
+	sent := func() int {
+		return len(pixela.requested())
+	}
+
+	getPixels()
+	if got := getPixels(); got != 0 || sent() != 1 {
+		t.Errorf("the response should be cached. pixels=%d sent=%d", got, sent())
+	}
+
+	httpCacheDisabled = true
+	if getPixels(); sent() != 2 {
+		t.Errorf("--no-cache should bypass the cache. sent=%d", sent())
+	}
+	req, _ := generateUpdatePixelRequest(&updatePixelCommand{ID: "test-id", Date: "20201019", Quantity: "5"})
+	if _, err := doRequestAndGetBody(req); err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+
+	httpCacheDisabled = false
+	if got := getPixels(); got != 1 || sent() != 4 {
+		t.Errorf("the update with --no-cache should invalidate the cache. pixels=%d sent=%d", got, sent())
+	}
+}
@@ -4,21 +4,64 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 type channelsCommand struct {
-	Post   createChannelCommand `description:"create Channel" command:"create" subcommands-optional:"true"`
-	Update updateChannelCommand `description:"update Channel Definition" command:"update" subcommands-optional:"true"`
-	Get    getChannelsCommand   `description:"get Channel Definitions" command:"get" subcommands-optional:"true"`
-	Delete deleteChannelCommand `description:"delete Channel" command:"delete" subcommands-optional:"true"`
+	Post    createChannelCommand    `description:"create Channel" command:"create" subcommands-optional:"true"`
+	Update  updateChannelCommand    `description:"update Channel Definition" command:"update" subcommands-optional:"true"`
+	Get     getChannelsCommand      `description:"get Channel Definitions" command:"get" subcommands-optional:"true"`
+	Delete  deleteChannelCommand    `description:"delete Channel" command:"delete" subcommands-optional:"true"`
+	Types   listChannelTypesCommand `description:"list supported Channel types" command:"types" subcommands-optional:"true"`
+	Test    testChannelCommand      `description:"show the payload of a test notification of Channel" command:"test" subcommands-optional:"true"`
+	Capture captureChannelCommand   `description:"serve a local receiver printing Channel payloads" command:"capture" subcommands-optional:"true"`
 }
 
+// createChannelCommand does not mark ID and Name as required, because the options of the subcommands such as slack
+// are given to the subcommand instead of this.
 type createChannelCommand struct {
-	Username string `short:"u" long:"username" description:"User name of channel owner."`
-	ID       string `short:"i" long:"channel-id" description:"ID for identifying the channel." required:"true"`
-	Name     string `short:"n" long:"name" description:"The name of the channel." required:"true"`
-	Type     string `short:"t" long:"type" description:"The type for notification." required:"true"`
-	Detail   string `short:"d" long:"detail" description:"Object that specifies the details of the type. It is specified as JSON string." required:"true"`
+	Username string                    `short:"u" long:"username" description:"User name of channel owner."`
+	ID       string                    `short:"i" long:"channel-id" description:"ID for identifying the channel."`
+	Name     string                    `short:"n" long:"name" description:"The name of the channel."`
+	Type     string                    `short:"t" long:"type" description:"The type for notification. Run 'pi channels types' for supported types."`
+	Detail   string                    `short:"d" long:"detail" description:"Object that specifies the details of the type. It is specified as JSON string."`
+	Slack    createSlackChannelCommand `description:"create Slack Channel" command:"slack" subcommands-optional:"true"`
+}
+
+type createSlackChannelCommand struct {
+	Username    string `short:"u" long:"username" description:"User name of channel owner."`
+	ID          string `short:"i" long:"channel-id" description:"ID for identifying the channel." required:"true"`
+	Name        string `short:"n" long:"name" description:"The name of the channel." required:"true"`
+	URL         string `long:"url" description:"The URL of Slack Incoming Webhook." required:"true"`
+	UserName    string `long:"user-name" description:"The user name shown in Slack." required:"true"`
+	ChannelName string `long:"channel-name" description:"The name of Slack channel to post." required:"true"`
+}
+
+type slackChannelDetail struct {
+	URL         string `json:"url"`
+	UserName    string `json:"userName"`
+	ChannelName string `json:"channelName"`
+}
+
+type listChannelTypesCommand struct{}
+
+type channelType struct {
+	Name        string
+	Description string
+	Detail      string
+	validate    func(detail []byte) error
+	render      func(channel *channelDefinition, text string) (string, []byte, error)
+}
+
+var channelTypes = []channelType{
+	{
+		Name:        "slack",
+		Description: "post to Slack Incoming Webhook",
+		Detail:      `{"url":"https://hooks.slack.com/services/...","userName":"...","channelName":"..."}`,
+		validate:    validateSlackChannelDetail,
+		render:      renderSlackPayload,
+	},
 }
 
 type createChannelParam struct {
@@ -67,6 +110,16 @@ func generateCreateChannelRequest(cC *createChannelCommand) (*http.Request, erro
 	if err != nil {
 		return nil, err
 	}
+	if cC.ID == "" || cC.Name == "" {
+		return nil, fmt.Errorf("--channel-id,-i and --name,-n are required")
+	}
+	if cC.Type == "" || cC.Detail == "" {
+		return nil, fmt.Errorf("specify --type,-t and --detail,-d, or use a subcommand such as `pi channels create slack`")
+	}
+	err = validateChannelDetail(cC.Type, cC.Detail)
+	if err != nil {
+		return nil, err
+	}
 
 	paramStruct := &createChannelParam{
 		ID:     cC.ID,
@@ -87,6 +140,35 @@ func generateCreateChannelRequest(cC *createChannelCommand) (*http.Request, erro
 	return req, nil
 }
 
+func (cS *createSlackChannelCommand) Execute(args []string) error {
+	req, err := generateCreateSlackChannelRequest(cS)
+	if err != nil {
+		return err
+	}
+
+	err = doRequest(req)
+	return err
+}
+
+func generateCreateSlackChannelRequest(cS *createSlackChannelCommand) (*http.Request, error) {
+	detail, err := json.Marshal(&slackChannelDetail{
+		URL:         cS.URL,
+		UserName:    cS.UserName,
+		ChannelName: cS.ChannelName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal detail : %s", err)
+	}
+
+	return generateCreateChannelRequest(&createChannelCommand{
+		Username: cS.Username,
+		ID:       cS.ID,
+		Name:     cS.Name,
+		Type:     "slack",
+		Detail:   string(detail),
+	})
+}
+
 func (uC *updateChannelCommand) Execute(args []string) error {
 	req, err := generateUpdateChannelRequest(uC)
 	if err != nil {
@@ -102,6 +184,17 @@ func generateUpdateChannelRequest(uC *updateChannelCommand) (*http.Request, erro
 	if err != nil {
 		return nil, err
 	}
+	if uC.Type != "" {
+		if uC.Detail == "" {
+			return nil, fmt.Errorf("--detail,-d is required to change the type")
+		}
+		err = validateChannelDetail(uC.Type, uC.Detail)
+		if err != nil {
+			return nil, err
+		}
+	} else if uC.Detail != "" && !json.Valid([]byte(uC.Detail)) {
+		return nil, fmt.Errorf("detail is not a valid JSON")
+	}
 
 	paramStruct := &updateChannelParam{
 		ID:     uC.ID,
@@ -176,3 +269,43 @@ func generateDeleteChannelRequest(dC *deleteChannelCommand) (*http.Request, erro
 	}
 	return req, nil
 }
+
+func (lT *listChannelTypesCommand) Execute(args []string) error {
+	for _, t := range channelTypes {
+		fmt.Printf("%s\t%s\t%s\n", t.Name, t.Description, t.Detail)
+	}
+	return nil
+}
+
+func validateChannelDetail(typ string, detail string) error {
+	if !json.Valid([]byte(detail)) {
+		return fmt.Errorf("detail is not a valid JSON")
+	}
+	names := []string{}
+	for _, t := range channelTypes {
+		if t.Name == typ {
+			return t.validate([]byte(detail))
+		}
+		names = append(names, t.Name)
+	}
+	return fmt.Errorf("unknown channel type `%s`. supported types are %s", typ, strings.Join(names, ", "))
+}
+
+func validateSlackChannelDetail(detail []byte) error {
+	d := &slackChannelDetail{}
+	err := json.Unmarshal(detail, d)
+	if err != nil {
+		return fmt.Errorf("invalid detail of slack : %s", err)
+	}
+	u, err := url.Parse(d.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("url of slack must be an https URL of Incoming Webhook : `%s`", d.URL)
+	}
+	if d.UserName == "" {
+		return fmt.Errorf("userName of slack is not specified")
+	}
+	if d.ChannelName == "" {
+		return fmt.Errorf("channelName of slack is not specified")
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package pi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type testChannelCommand struct {
+	Username   string `short:"u" long:"username" description:"User name of channel owner."`
+	ID         string `short:"i" long:"channel-id" description:"ID for identifying the channel." required:"true"`
+	Text       string `long:"text" description:"The message of the test notification." default:"This is a test notification from pi."`
+	Local      bool   `long:"local" description:"Post the payload to a local capture server instead of only showing it."`
+	CaptureURL string `long:"capture-url" description:"The URL of the capture server used with --local." default:"http://localhost:9000"`
+}
+
+type captureChannelCommand struct {
+	Addr string `long:"addr" description:"The address to listen on." default:":9000"`
+}
+
+type channelDefinition struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Detail json.RawMessage `json:"detail"`
+}
+
+type channelDefinitions struct {
+	Channels []channelDefinition `json:"channels"`
+}
+
+type slackPayload struct {
+	Text     string `json:"text"`
+	Username string `json:"username"`
+	Channel  string `json:"channel"`
+}
+
+type captureHandler struct {
+	mu  sync.Mutex
+	out io.Writer
+	now func() time.Time
+}
+
+func (tC *testChannelCommand) Execute(args []string) error {
+	username, err := getUsername(tC.Username)
+	if err != nil {
+		return err
+	}
+
+	channel, err := fetchChannelDefinition(username, tC.ID)
+	if err != nil {
+		return err
+	}
+	target, payload, err := renderChannelPayload(channel, tC.Text)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("POST %s\n%s\n", target, indentJSON(payload))
+
+	if !tC.Local {
+		return nil
+	}
+	return postToCaptureServer(tC.CaptureURL, target, payload)
+}
+
+func (cC *captureChannelCommand) Execute(args []string) error {
+	log.Printf("capturing payloads on %s", cC.Addr)
+	return http.ListenAndServe(cC.Addr, &captureHandler{out: os.Stdout, now: time.Now})
+}
+
+func fetchChannelDefinitions(username string) ([]channelDefinition, error) {
+	req, err := generateGetChannelsRequest(&getChannelsCommand{Username: username})
+	if err != nil {
+		return nil, err
+	}
+	b, err := doRequestAndGetBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := &channelDefinitions{}
+	err = json.Unmarshal(b, defs)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse channels : %s", err)
+	}
+	return defs.Channels, nil
+}
+
+func fetchChannelDefinition(username string, id string) (*channelDefinition, error) {
+	defs, err := fetchChannelDefinitions(username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range defs {
+		if defs[i].ID == id {
+			return &defs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("channel `%s` is not found", id)
+}
+
+// renderChannelPayload returns the destination and the body which the channel receives on notification.
+func renderChannelPayload(channel *channelDefinition, text string) (string, []byte, error) {
+	for _, t := range channelTypes {
+		if t.Name == channel.Type {
+			return t.render(channel, text)
+		}
+	}
+	return "", nil, fmt.Errorf("rendering payload of channel type `%s` is not supported", channel.Type)
+}
+
+func renderSlackPayload(channel *channelDefinition, text string) (string, []byte, error) {
+	err := validateSlackChannelDetail(channel.Detail)
+	if err != nil {
+		return "", nil, err
+	}
+	detail := &slackChannelDetail{}
+	err = json.Unmarshal(channel.Detail, detail)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid detail of slack : %s", err)
+	}
+
+	b, err := json.Marshal(&slackPayload{
+		Text:     fmt.Sprintf("[%s] %s", channel.Name, text),
+		Username: detail.UserName,
+		Channel:  detail.ChannelName,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("Failed to marshal payload : %s", err)
+	}
+	return detail.URL, b, nil
+}
+
+// postToCaptureServer posts the payload keeping the path of the original destination, so that the capture server can show it.
+func postToCaptureServer(captureURL string, target string, payload []byte) error {
+	base, err := url.Parse(captureURL)
+	if err != nil {
+		return fmt.Errorf("invalid capture url `%s`", captureURL)
+	}
+	t, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid destination `%s`", target)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/") + t.Path
+
+	resp, err := http.Post(base.String(), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("Failed to post to capture server : %s", err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	fmt.Printf("capture server responded %s %s\n", resp.Status, strings.TrimSpace(string(b)))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("capture server returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (h *captureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read payload", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	fmt.Fprintf(h.out, "--- %s %s %s (%s)\n", h.now().Format(time.RFC3339), r.Method, r.URL.RequestURI(), r.Header.Get("Content-Type"))
+	fmt.Fprintln(h.out, indentJSON(body))
+	h.mu.Unlock()
+
+	// the same response as Slack Incoming Webhooks.
+	fmt.Fprint(w, "ok")
+}
+
+// indentJSON returns the body as it is when it is not a JSON.
+func indentJSON(b []byte) string {
+	out := &bytes.Buffer{}
+	if err := json.Indent(out, b, "", "  "); err != nil {
+		return string(b)
+	}
+	return out.String()
+}
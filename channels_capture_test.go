@@ -0,0 +1,93 @@
+package pi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var channelCaptureTests = []struct {
+	name     string
+	input    []string
+	exitCode int
+}{
+	{
+		name:     "test channel - not specify channel-id",
+		input:    []string{"channels", "test", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "test channel - not specify username",
+		input:    []string{"channels", "test", "--channel-id", "test-id"},
+		exitCode: 1,
+	},
+}
+
+func TestChannelCapture(t *testing.T) {
+	for _, tt := range channelCaptureTests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestRenderChannelPayload(t *testing.T) {
+	channel := &channelDefinition{
+		ID:     "test-id",
+		Name:   "test-name",
+		Type:   "slack",
+		Detail: []byte(`{"url":"https://hooks.slack.com/services/T035DA4QD/B06LMAV40/xxxx","userName":"Pixela Notification","channelName":"pixela-notify"}`),
+	}
+	target, payload, err := renderChannelPayload(channel, "hello")
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if target != "https://hooks.slack.com/services/T035DA4QD/B06LMAV40/xxxx" {
+		t.Errorf("Unexpected destination. %s", target)
+	}
+	if string(payload) != `{"text":"[test-name] hello","username":"Pixela Notification","channel":"pixela-notify"}` {
+		t.Errorf("Unexpected payload. %s", string(payload))
+	}
+
+	channel.Detail = []byte(`{"url":"not-a-url","userName":"Pixela Notification","channelName":"pixela-notify"}`)
+	if _, _, err := renderChannelPayload(channel, "hello"); err == nil {
+		t.Errorf("invalid detail: expected error but not occurred")
+	}
+	channel.Type = "mail"
+	if _, _, err := renderChannelPayload(channel, "hello"); err == nil {
+		t.Errorf("unknown type: expected error but not occurred")
+	}
+}
+
+func TestCaptureServer(t *testing.T) {
+	out := &bytes.Buffer{}
+	handler := &captureHandler{
+		out: out,
+		now: func() time.Time { return time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC) },
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	err := postToCaptureServer(server.URL+"/", "https://hooks.slack.com/services/xxxx", []byte(`{"text":"hello"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	want := "--- 2019-01-01T00:00:00Z POST /services/xxxx (application/json)\n{\n  \"text\": \"hello\"\n}\n"
+	if out.String() != want {
+		t.Errorf("Unexpected capture output.\n%s", out.String())
+	}
+
+	out.Reset()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/form", strings.NewReader("payload=x")))
+	if rec.Body.String() != "ok" || !strings.Contains(out.String(), "payload=x") {
+		t.Errorf("Unexpected capture of non JSON payload. %s", out.String())
+	}
+}
@@ -36,6 +36,36 @@ var channelTests = []struct {
 		input:    []string{"channels", "create", "--username", "c-know", "--channel-id", "test-id", "--name", "test-name", "--type", "slack"},
 		exitCode: 1,
 	},
+	{
+		name:     "create channel - unknown type",
+		input:    []string{"channels", "create", "--username", "c-know", "--channel-id", "test-id", "--name", "test-name", "--type", "mail", "--detail", `{"address":"a-know@example.com"}`},
+		exitCode: 1,
+	},
+	{
+		name:     "create slack channel - not specify url",
+		input:    []string{"channels", "create", "slack", "--username", "c-know", "--channel-id", "test-id", "--name", "test-name", "--user-name", "Pixela Notification", "--channel-name", "pixela-notify"},
+		exitCode: 1,
+	},
+	{
+		name:     "create slack channel - invalid url",
+		input:    []string{"channels", "create", "slack", "--username", "c-know", "--channel-id", "test-id", "--name", "test-name", "--url", "hooks.slack.com/services/xxxx", "--user-name", "Pixela Notification", "--channel-name", "pixela-notify"},
+		exitCode: 1,
+	},
+	{
+		name:     "create slack channel - not specify name",
+		input:    []string{"channels", "create", "slack", "--username", "c-know", "--channel-id", "test-id", "--url", "https://hooks.slack.com/services/xxxx", "--user-name", "Pixela Notification", "--channel-name", "pixela-notify"},
+		exitCode: 1,
+	},
+	{
+		name:     "update channel - unknown type",
+		input:    []string{"channels", "update", "--username", "c-know", "--channel-id", "test-id", "--type", "mail", "--detail", `{"address":"a-know@example.com"}`},
+		exitCode: 1,
+	},
+	{
+		name:     "list channel types",
+		input:    []string{"channels", "types"},
+		exitCode: 0,
+	},
 	{
 		name:     "update channel - not specify username",
 		input:    []string{"channels", "update", "--name", "test-name", "--channel-id", "test-id", "--type", "slack", "--detail", `{"url":"https://hooks.slack.com/services/T035DA4QD/B06LMAV40/xxxx","userName":"Pixela Notification","channelName":"pixela-notify"}`},
@@ -266,3 +296,63 @@ func TestGenerateDeleteChannelRequest(t *testing.T) {
 		t.Errorf("Unexpected request body. %s", string(b))
 	}
 }
+
+func TestGenerateCreateSlackChannelRequest(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+
+	cmd := &createSlackChannelCommand{
+		Username:    "c-know",
+		ID:          "test-id",
+		Name:        "test-name",
+		URL:         "https://hooks.slack.com/services/T035DA4QD/B06LMAV40/xxxx",
+		UserName:    "Pixela Notification",
+		ChannelName: "pixela-notify",
+	}
+
+	// run
+	req, err := generateCreateSlackChannelRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "POST" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/v1/users/c-know/channels", afterAPIBaseEnv) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		t.Errorf("Failed to read request body. %s", err)
+	}
+	if string(b) != `{"id":"test-id","name":"test-name","type":"slack","detail":{"url":"https://hooks.slack.com/services/T035DA4QD/B06LMAV40/xxxx","userName":"Pixela Notification","channelName":"pixela-notify"}}` {
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}
+
+func TestValidateChannelDetail(t *testing.T) {
+	tests := []struct {
+		typ     string
+		detail  string
+		wantErr bool
+	}{
+		{"slack", `{"url":"https://hooks.slack.com/services/xxxx","userName":"pi","channelName":"notify"}`, false},
+		{"slack", `{"url":"http://hooks.slack.com/services/xxxx","userName":"pi","channelName":"notify"}`, true},
+		{"slack", `{"url":"https://hooks.slack.com/services/xxxx","channelName":"notify"}`, true},
+		{"slack", `{"url":"https://hooks.slack.com/services/xxxx","userName":"pi"}`, true},
+		{"slack", `{"url":`, true},
+		{"mail", `{}`, true},
+	}
+	for _, tt := range tests {
+		err := validateChannelDetail(tt.typ, tt.detail)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s %s: unexpected result. %v", tt.typ, tt.detail, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
 
 	flags "github.com/jessevdk/go-flags"
 )
@@ -36,6 +37,7 @@ func (cli *CLI) Run(argv []string) int {
 }
 
 type piOpts struct {
+	NoCache       func()               `long:"no-cache" description:"Do not use cached responses of Pixela API."`
 	Users         usersCommand         `description:"operate Users" command:"users" subcommands-optional:"true"`
 	Channels      channelsCommand      `description:"operate Channels" command:"channels" subcommands-optional:"true"`
 	Graphs        graphsCommand        `description:"operate Graphs" command:"graphs" subcommands-optional:"true"`
@@ -43,6 +45,16 @@ type piOpts struct {
 	Webhooks      webhooksCommand      `description:"operate Webhooks" command:"webhooks" subcommands-optional:"true"`
 	Ver           verCommand           `description:"display version" command:"version" subcommands-optional:"true"`
 	Notifications notificationsCommand `description:"operate Notifications" command:"ntf" subcommands-optional:"true"`
+	Dashboard     dashboardCommand     `description:"show a summary of Graphs" command:"dashboard" subcommands-optional:"true"`
+	Integrate     integrateCommand     `description:"integrate with other tools" command:"integrate" subcommands-optional:"true"`
+	Hooks         hooksCommand         `description:"manage git hooks" command:"hooks" subcommands-optional:"true"`
+	Timer         timerCommand         `description:"track time into Graph" command:"timer" subcommands-optional:"true"`
+	Relay         relayCommand         `description:"relay external events to Pixela" command:"relay" subcommands-optional:"true"`
+	Cache         cacheCommand         `description:"manage cached responses" command:"cache" subcommands-optional:"true"`
+	Completion    completionCommand    `description:"print a shell completion script" command:"completion" subcommands-optional:"true"`
+	TUI           tuiCommand           `description:"browse and edit Graphs interactively" command:"tui" subcommands-optional:"true"`
+	Templates     templatesCommand     `description:"manage Graph templates" command:"templates" subcommands-optional:"true"`
+	Goals         goalsCommand         `description:"track goals of Graphs" command:"goals" subcommands-optional:"true"`
 }
 
 type verCommand struct{}
@@ -53,7 +65,14 @@ func (b *verCommand) Execute(args []string) error {
 }
 
 func parseArgs(args []string) error {
-	opts := &piOpts{}
-	_, err := flags.ParseArgs(opts, args)
+	httpCacheDisabled = false
+	opts := &piOpts{
+		NoCache: func() { httpCacheDisabled = true },
+	}
+	parser := flags.NewParser(opts, flags.Default)
+	parser.CompletionHandler = func(items []flags.Completion) {
+		printCompletions(os.Stdout, completeArgs(parser, args, items), os.Getenv("GO_FLAGS_COMPLETION") == "verbose")
+	}
+	_, err := parser.ParseArgs(args)
 	return err
 }
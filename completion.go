@@ -0,0 +1,287 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+type completionCommand struct{}
+
+type completionCache struct {
+	Updated time.Time `json:"updated"`
+	Values  []string  `json:"values"`
+}
+
+type webhookDefinitions struct {
+	Webhooks []struct {
+		WebhookHash string `json:"webhookHash"`
+		GraphID     string `json:"graphID"`
+		Type        string `json:"type"`
+	} `json:"webhooks"`
+}
+
+const completionCacheTTL = 10 * time.Minute
+
+var completionNow = time.Now
+
+// completionScripts call pi with `GO_FLAGS_COMPLETION` environment variable, which makes go-flags print the candidates.
+var completionScripts = map[string]string{
+	"bash": `_pi() {
+    local IFS=$'\n'
+    COMPREPLY=($(GO_FLAGS_COMPLETION=1 "${COMP_WORDS[0]}" "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+    return 0
+}
+complete -o default -F _pi pi
+`,
+	"zsh": `#compdef pi
+_pi() {
+    local -a candidates
+    candidates=("${(@f)$(GO_FLAGS_COMPLETION=1 "${words[1]}" "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    compadd -a candidates
+}
+compdef _pi pi
+`,
+	"fish": `function __pi_complete
+    set -l args (commandline -opc) (commandline -ct)
+    set -e args[1]
+    env GO_FLAGS_COMPLETION=1 pi $args 2>/dev/null
+end
+complete -c pi -f -a '(__pi_complete)'
+`,
+}
+
+// completionSources fetch the values of options which depend on the resources of the user, keyed by the long name of the option.
+var completionSources = map[string]func(username string, graphID string) ([]string, error){
+	"graph-id": func(username string, graphID string) ([]string, error) {
+		defs, err := fetchGraphDefinitions(username)
+		if err != nil {
+			return nil, err
+		}
+		values := []string{}
+		for _, d := range defs {
+			values = append(values, d.ID)
+		}
+		return values, nil
+	},
+	"webhookHash": func(username string, graphID string) ([]string, error) {
+		req, err := generateGetWebhooksRequest(&getWebhooksCommand{Username: username})
+		if err != nil {
+			return nil, err
+		}
+		b, err := doRequestAndGetBody(req)
+		if err != nil {
+			return nil, err
+		}
+		defs := &webhookDefinitions{}
+		err = json.Unmarshal(b, defs)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse webhooks : %s", err)
+		}
+		values := []string{}
+		for _, w := range defs.Webhooks {
+			values = append(values, w.WebhookHash)
+		}
+		return values, nil
+	},
+	"channel-id": func(username string, graphID string) ([]string, error) {
+		defs, err := fetchChannelDefinitions(username)
+		if err != nil {
+			return nil, err
+		}
+		values := []string{}
+		for _, c := range defs {
+			values = append(values, c.ID)
+		}
+		return values, nil
+	},
+	"notifiation-id": func(username string, graphID string) ([]string, error) {
+		if graphID == "" {
+			return nil, nil
+		}
+		rules, err := fetchNotificationRules(username, graphID)
+		if err != nil {
+			return nil, err
+		}
+		values := []string{}
+		for _, r := range rules {
+			values = append(values, r.ID)
+		}
+		return values, nil
+	},
+}
+
+func (c *completionCommand) Execute(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("specify the shell: bash, zsh or fish")
+	}
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		return fmt.Errorf("unsupported shell `%s`. supported shells are bash, zsh and fish", args[0])
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// completeArgs adds the candidates of option values which go-flags does not complete,
+// i.e. choices and the IDs of the user's resources.
+func completeArgs(parser *flags.Parser, args []string, items []flags.Completion) []flags.Completion {
+	if len(items) > 0 || len(args) == 0 {
+		return items
+	}
+
+	last := args[len(args)-1]
+	var name, prefix, match string
+	if i := strings.Index(last, "="); strings.HasPrefix(last, "--") && i > 0 {
+		name, prefix, match = last[:i], last[:i+1], last[i+1:]
+	} else if len(args) >= 2 && strings.HasPrefix(args[len(args)-2], "-") && !strings.Contains(args[len(args)-2], "=") {
+		name, match = args[len(args)-2], last
+	} else {
+		return items
+	}
+
+	chain := []*flags.Command{parser.Command}
+	for _, arg := range args[:len(args)-1] {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if cmd := chain[len(chain)-1].Find(arg); cmd != nil {
+			chain = append(chain, cmd)
+		}
+	}
+	opt := findCompletionOption(chain, name)
+	if opt == nil {
+		return items
+	}
+
+	values := opt.Choices
+	if len(values) == 0 {
+		values = completionValues(chain, opt.LongName, args)
+	}
+	for _, v := range values {
+		if strings.HasPrefix(v, match) {
+			items = append(items, flags.Completion{Item: prefix + v})
+		}
+	}
+	return items
+}
+
+func findCompletionOption(chain []*flags.Command, name string) *flags.Option {
+	for i := len(chain) - 1; i >= 0; i-- {
+		var opt *flags.Option
+		if strings.HasPrefix(name, "--") {
+			opt = chain[i].FindOptionByLongName(strings.TrimPrefix(name, "--"))
+		} else if len(name) == 2 {
+			opt = chain[i].FindOptionByShortName(rune(name[1]))
+		}
+		if opt != nil {
+			return opt
+		}
+	}
+	return nil
+}
+
+func completionValues(chain []*flags.Command, longName string, args []string) []string {
+	command := chain[len(chain)-1].Name
+	switch longName {
+	case "mode":
+		if command == "svg" {
+			return []string{"short", "badge", "line"}
+		}
+		return nil
+	case "appearance":
+		return []string{"dark"}
+	case "template":
+		templates, err := loadGraphTemplates()
+		if err != nil {
+			return nil
+		}
+		names := []string{}
+		for name := range templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	case "type":
+		if len(chain) > 1 && chain[1].Name == "channels" {
+			names := []string{}
+			for _, t := range channelTypes {
+				names = append(names, t.Name)
+			}
+			return names
+		}
+		return nil
+	}
+
+	if _, ok := completionSources[longName]; !ok {
+		return nil
+	}
+	username, err := getUsername(completionOptionValue(args, "-u", "--username"))
+	if err != nil {
+		return nil
+	}
+	return cachedCompletionValues(username, longName, completionOptionValue(args, "-g", "--graph-id"))
+}
+
+// completionOptionValue returns the value of the option already typed in the command line.
+func completionOptionValue(args []string, short string, long string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == short || args[i] == long {
+			return args[i+1]
+		}
+		if strings.HasPrefix(args[i], long+"=") {
+			return strings.TrimPrefix(args[i], long+"=")
+		}
+	}
+	return ""
+}
+
+// cachedCompletionValues keeps the fetched values for a while, so that completion does not call the API on every key stroke.
+// The expired values are used if the API can not be called.
+func cachedCompletionValues(username string, longName string, graphID string) []string {
+	key := longName
+	if longName == "notifiation-id" {
+		key = fmt.Sprintf("%s-%s", longName, graphID)
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return nil
+	}
+	path := filepath.Join(dir, "completion", url.PathEscape(username), url.PathEscape(key)+".json")
+
+	cache := &completionCache{}
+	if b, err := ioutil.ReadFile(path); err == nil {
+		if json.Unmarshal(b, cache) == nil && completionNow().Sub(cache.Updated) < completionCacheTTL {
+			return cache.Values
+		}
+	}
+
+	values, err := completionSources[longName](username, graphID)
+	if err != nil {
+		return cache.Values
+	}
+	b, err := json.Marshal(&completionCache{Updated: completionNow(), Values: values})
+	if err == nil && os.MkdirAll(filepath.Dir(path), 0700) == nil {
+		ioutil.WriteFile(path, b, 0600)
+	}
+	return values
+}
+
+func printCompletions(out io.Writer, items []flags.Completion, verbose bool) {
+	for _, item := range items {
+		if verbose && item.Description != "" && len(items) > 1 {
+			fmt.Fprintf(out, "%s  # %s\n", item.Item, item.Description)
+			continue
+		}
+		fmt.Fprintln(out, item.Item)
+	}
+}
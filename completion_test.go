@@ -0,0 +1,127 @@
+package pi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+var completionTests = []struct {
+	name     string
+	input    []string
+	exitCode int
+}{
+	{
+		name:     "completion - not specify shell",
+		input:    []string{"completion"},
+		exitCode: 1,
+	},
+	{
+		name:     "completion - unsupported shell",
+		input:    []string{"completion", "tcsh"},
+		exitCode: 1,
+	},
+}
+
+func TestCompletion(t *testing.T) {
+	for _, tt := range completionTests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func completionItems(args ...string) string {
+	parser := flags.NewParser(&piOpts{}, flags.None)
+	items := []string{}
+	for _, item := range completeArgs(parser, args, nil) {
+		items = append(items, item.Item)
+	}
+	return strings.Join(items, ",")
+}
+
+func TestCompleteChoices(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"graphs", "create", "--color", "s"}, "shibafu,sora"},
+		{[]string{"graphs", "update", "--color=m"}, "--color=momiji"},
+		{[]string{"graphs", "create", "-t", ""}, "int,float"},
+		{[]string{"webhooks", "create", "--type", "i"}, "increment"},
+		{[]string{"channels", "create", "-t", ""}, "slack"},
+		{[]string{"graphs", "svg", "-m", "b"}, "badge"},
+		{[]string{"graphs", "detail", "-m", ""}, "simple,simple-short"},
+		{[]string{"graphs", "create", "--name", ""}, ""},
+		{[]string{"graphs", "create", "s"}, ""},
+	}
+	for _, tt := range tests {
+		if got := completionItems(tt.args...); got != tt.want {
+			t.Errorf("%v: got=%s want=%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestCompleteIDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-cache")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	beforeCacheDir := os.Getenv("PI_CACHE_DIR")
+	os.Setenv("PI_CACHE_DIR", dir)
+	defer os.Setenv("PI_CACHE_DIR", beforeCacheDir)
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	completionNow = func() time.Time { return now }
+	defer func() { completionNow = time.Now }()
+
+	calls := []string{}
+	failing := false
+	for _, name := range []string{"graph-id", "notifiation-id"} {
+		name := name
+		before := completionSources[name]
+		defer func() { completionSources[name] = before }()
+		completionSources[name] = func(username string, graphID string) ([]string, error) {
+			calls = append(calls, fmt.Sprintf("%s %s %s", name, username, graphID))
+			if failing {
+				return nil, fmt.Errorf("failed")
+			}
+			return []string{"test-a", "test-b", "other"}, nil
+		}
+	}
+
+	if got := completionItems("pixel", "increment", "-u", "c-know", "-g", "test"); got != "test-a,test-b" {
+		t.Errorf("Unexpected graph IDs. %s", got)
+	}
+	if got := completionItems("graphs", "pixels", "--username=c-know", "--graph-id", "o"); got != "other" {
+		t.Errorf("Unexpected graph IDs. %s", got)
+	}
+	if got := completionItems("ntf", "delete", "-u", "c-know", "-g", "test-a", "-i", ""); got != "test-a,test-b,other" {
+		t.Errorf("Unexpected notification IDs. %s", got)
+	}
+
+	// the values are cached until they expire, and expired values are used when the API fails.
+	now = now.Add(completionCacheTTL)
+	failing = true
+	if got := completionItems("pixel", "increment", "-u", "c-know", "-g", ""); got != "test-a,test-b,other" {
+		t.Errorf("Unexpected graph IDs from expired cache. %s", got)
+	}
+	want := []string{
+		"graph-id c-know test",
+		"notifiation-id c-know test-a",
+		"graph-id c-know ",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected calls.\n%s", strings.Join(calls, "\n"))
+	}
+}
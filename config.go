@@ -0,0 +1,134 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+type piConfig struct {
+	Dashboard dashboardConfig           `json:"dashboard"`
+	Cache     cacheConfig               `json:"cache"`
+	RateLimit rateLimitConfig           `json:"rateLimit"`
+	Templates map[string]*graphTemplate `json:"templates"`
+	Tokens    map[string]string         `json:"tokens"`
+}
+
+type dashboardConfig struct {
+	Graphs []string `json:"graphs"`
+}
+
+type cacheConfig struct {
+	TTL string `json:"ttl"`
+}
+
+type rateLimitConfig struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// configPath returns the path of the config file. It can be overridden by `PI_CONFIG` environment variable.
+func configPath() (string, error) {
+	if path := os.Getenv("PI_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("Failed to find config directory : %s", err)
+	}
+	return filepath.Join(dir, "pi", "config.json"), nil
+}
+
+// dataDir returns the directory where pi keeps its local state such as running timers.
+// It can be overridden by `PI_DATA_DIR` environment variable.
+func dataDir() (string, error) {
+	if dir := os.Getenv("PI_DATA_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("Failed to find config directory : %s", err)
+	}
+	return filepath.Join(dir, "pi"), nil
+}
+
+// loadDataFile reads the JSON file `name` in dataDir into `v`, which is left as it is if the file does not exist.
+// `what` describes the data in the errors.
+func loadDataFile(name string, what string, v interface{}) error {
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to read %s : %s", what, err)
+	}
+	err = json.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("Failed to parse %s %s : %s", what, path, err)
+	}
+	return nil
+}
+
+// saveDataFile writes `v` to the JSON file `name` in dataDir, which is readable only by the user.
+func saveDataFile(name string, what string, v interface{}) error {
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal %s : %s", what, err)
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("Failed to create data directory : %s", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, name), b, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write %s : %s", what, err)
+	}
+	return nil
+}
+
+// cacheDir returns the directory where pi keeps data which can be fetched again.
+// It can be overridden by `PI_CACHE_DIR` environment variable.
+func cacheDir() (string, error) {
+	if dir := os.Getenv("PI_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("Failed to find cache directory : %s", err)
+	}
+	return filepath.Join(dir, "pi"), nil
+}
+
+// loadConfig returns an empty config if the config file does not exist.
+func loadConfig() (*piConfig, error) {
+	config := &piConfig{}
+
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read config file : %s", err)
+	}
+
+	err = json.Unmarshal(b, config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse config file %s : %s", path, err)
+	}
+	return config, nil
+}
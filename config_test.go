@@ -0,0 +1,74 @@
+package pi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-config")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{"dashboard":{"graphs":["test-id","a-know/test-id"]}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config. %s", err)
+	}
+
+	beforeConfigEnv := os.Getenv("PI_CONFIG")
+	os.Setenv("PI_CONFIG", path)
+	config, err := loadConfig()
+	os.Setenv("PI_CONFIG", beforeConfigEnv)
+
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if len(config.Dashboard.Graphs) != 2 || config.Dashboard.Graphs[1] != "a-know/test-id" {
+		t.Errorf("Unexpected config. %+v", config)
+	}
+}
+
+func TestLoadConfigNotExist(t *testing.T) {
+	beforeConfigEnv := os.Getenv("PI_CONFIG")
+	os.Setenv("PI_CONFIG", filepath.Join(os.TempDir(), "pi-config-not-exist", "config.json"))
+	config, err := loadConfig()
+	os.Setenv("PI_CONFIG", beforeConfigEnv)
+
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if len(config.Dashboard.Graphs) != 0 {
+		t.Errorf("Unexpected config. %+v", config)
+	}
+}
+
+func TestDataFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-data")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	beforeDataDirEnv := os.Getenv("PI_DATA_DIR")
+	os.Setenv("PI_DATA_DIR", filepath.Join(dir, "data"))
+	defer os.Setenv("PI_DATA_DIR", beforeDataDirEnv)
+
+	v := map[string]int{}
+	if err := loadDataFile("test.json", "test", &v); err != nil || len(v) != 0 {
+		t.Errorf("Unexpected result of a file which does not exist. %v %s", v, err)
+	}
+	if err := saveDataFile("test.json", "test", map[string]int{"a": 1}); err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if err := loadDataFile("test.json", "test", &v); err != nil || v["a"] != 1 {
+		t.Errorf("Unexpected result. %v %s", v, err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "data", "test.json"), []byte("{"), 0600)
+	if err := loadDataFile("test.json", "test", &v); err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
@@ -0,0 +1,218 @@
+package pi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type dashboardCommand struct {
+	Serve    dashboardServeCommand `description:"serve a dashboard page" command:"serve" subcommands-optional:"true"`
+	Username string                `short:"u" long:"username" description:"User name of graph owner."`
+	Graphs   []string              `short:"g" long:"graph-id" description:"ID of the graph to summarize. A graph of other user can be specified as <username>/<graph-id>. Multiple params can be specified. Defaults to the graphs in the config file, or all graphs of the user."`
+	Days     int                   `long:"days" description:"The number of days shown in the sparkline." default:"30"`
+}
+
+type graphSummary struct {
+	Ref       graphRef
+	Name      string
+	Unit      string
+	Today     float64
+	Week      float64
+	Month     float64
+	Sparkline string
+	graph     *graphDefinition
+	err       error
+}
+
+var sparklineBars = []rune("▁▂▃▄▅▆▇█")
+
+func (d *dashboardCommand) Execute(args []string) error {
+	username, err := getUsername(d.Username)
+	if err != nil {
+		return err
+	}
+	if d.Days < 1 {
+		return fmt.Errorf("days must be greater than 0")
+	}
+
+	refs, err := dashboardGraphRefs(username, d.Graphs)
+	if err != nil {
+		return err
+	}
+
+	summaries := fetchGraphSummaries(refs, d.Days)
+	printGraphSummaries(os.Stdout, summaries)
+
+	for _, s := range summaries {
+		if s.err != nil {
+			return fmt.Errorf("Failed to summarize some graphs")
+		}
+	}
+	return nil
+}
+
+// dashboardGraphRefs resolves the graphs to summarize in order of command line option, config file and all graphs of the user.
+func dashboardGraphRefs(username string, graphs []string) ([]graphRef, error) {
+	if len(graphs) == 0 {
+		config, err := loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		graphs = config.Dashboard.Graphs
+	}
+
+	refs := []graphRef{}
+	if len(graphs) == 0 {
+		defs, err := fetchGraphDefinitions(username)
+		if err != nil {
+			return nil, err
+		}
+		for _, def := range defs {
+			refs = append(refs, graphRef{Username: username, ID: def.ID})
+		}
+		return refs, nil
+	}
+
+	for _, g := range graphs {
+		refs = append(refs, parseGraphRef(g, username))
+	}
+	return refs, nil
+}
+
+// fetchGraphSummaries fetches the graphs concurrently. The graph definitions are fetched once per user.
+func fetchGraphSummaries(refs []graphRef, days int) []*graphSummary {
+	defs := map[string][]graphDefinition{}
+	defErrs := map[string]error{}
+	for _, ref := range refs {
+		if _, ok := defs[ref.Username]; ok {
+			continue
+		}
+		if _, ok := defErrs[ref.Username]; ok {
+			continue
+		}
+		d, err := fetchGraphDefinitions(ref.Username)
+		if err != nil {
+			defErrs[ref.Username] = err
+			continue
+		}
+		defs[ref.Username] = d
+	}
+
+	summaries := make([]*graphSummary, len(refs))
+	wg := &sync.WaitGroup{}
+	for i, ref := range refs {
+		summaries[i] = &graphSummary{Ref: ref}
+		if err, ok := defErrs[ref.Username]; ok {
+			summaries[i].err = err
+			continue
+		}
+		for j := range defs[ref.Username] {
+			if defs[ref.Username][j].ID == ref.ID {
+				summaries[i].graph = &defs[ref.Username][j]
+			}
+		}
+		if summaries[i].graph == nil {
+			summaries[i].err = fmt.Errorf("graph `%s` is not found", ref)
+			continue
+		}
+
+		wg.Add(1)
+		go func(s *graphSummary) {
+			defer wg.Done()
+			s.err = s.fetch(days)
+		}(summaries[i])
+	}
+	wg.Wait()
+	return summaries
+}
+
+func (s *graphSummary) fetch(days int) error {
+	today, err := s.graph.today()
+	if err != nil {
+		return err
+	}
+	// fetch at least 30 days for the totals.
+	span := days
+	if span < 30 {
+		span = 30
+	}
+	from := today.AddDate(0, 0, -(span - 1))
+
+	pixels, err := fetchGraphPixels(s.Ref.Username, s.Ref.ID, from.Format(pixelDateLayout), today.Format(pixelDateLayout))
+	if err != nil {
+		return err
+	}
+	return s.summarize(pixels, today, days)
+}
+
+func (s *graphSummary) summarize(pixels []pixel, today time.Time, days int) error {
+	quantities, err := pixelQuantities(pixels)
+	if err != nil {
+		return err
+	}
+
+	s.Name = s.graph.Name
+	s.Unit = s.graph.Unit
+	values := []float64{}
+	for i := 0; i < 30 || i < days; i++ {
+		q := quantities[today.AddDate(0, 0, -i).Format(pixelDateLayout)]
+		if i == 0 {
+			s.Today = q
+		}
+		if i < 7 {
+			s.Week += q
+		}
+		if i < 30 {
+			s.Month += q
+		}
+		if i < days {
+			values = append([]float64{q}, values...)
+		}
+	}
+	s.Sparkline = sparkline(values)
+	return nil
+}
+
+// sparkline scales the values between zero (or the minimum if it is negative) and the maximum.
+func sparkline(values []float64) string {
+	min, max := 0.0, 0.0
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > min {
+			i = int(math.Round((v - min) / (max - min) * float64(len(sparklineBars)-1)))
+		}
+		b.WriteRune(sparklineBars[i])
+	}
+	return b.String()
+}
+
+func printGraphSummaries(out io.Writer, summaries []*graphSummary) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "graph\tname\tunit\ttoday\t7 days\t30 days\ttrend")
+	for _, s := range summaries {
+		if s.err != nil {
+			fmt.Fprintf(w, "%s\terror: %s\t\t\t\t\t\n", s.Ref, strings.TrimSpace(s.err.Error()))
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.Ref, s.Name, s.Unit,
+			s.graph.formatQuantity(s.Today),
+			s.graph.formatQuantity(s.Week),
+			s.graph.formatQuantity(s.Month),
+			s.Sparkline,
+		)
+	}
+	w.Flush()
+}
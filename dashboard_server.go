@@ -0,0 +1,306 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type dashboardServeCommand struct {
+	Addr     string        `long:"addr" description:"The address to listen on." default:":8080"`
+	Layout   string        `short:"l" long:"layout" description:"Path of the layout file (JSON). If omitted, the graphs of the dashboard command are shown."`
+	CacheTTL time.Duration `long:"cache-ttl" description:"How long responses of Pixela are cached in the server." default:"5m"`
+}
+
+type dashboardLayout struct {
+	Title   string            `json:"title"`
+	Columns int               `json:"columns"`
+	Refresh int               `json:"refresh"`
+	Graphs  []dashboardWidget `json:"graphs"`
+}
+
+type dashboardWidget struct {
+	Graph      string `json:"graph"`
+	Title      string `json:"title"`
+	Mode       string `json:"mode"`
+	Appearance string `json:"appearance"`
+	ref        graphRef
+}
+
+type graphStats struct {
+	TotalPixelsCount int     `json:"totalPixelsCount"`
+	MaxQuantity      float64 `json:"maxQuantity"`
+	MinQuantity      float64 `json:"minQuantity"`
+	TotalQuantity    float64 `json:"totalQuantity"`
+	AvgQuantity      float64 `json:"avgQuantity"`
+	TodaysQuantity   float64 `json:"todaysQuantity"`
+}
+
+// ttlCache keeps responses of Pixela in memory so that page views do not hit the API.
+type ttlCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]ttlCacheEntry
+}
+
+type ttlCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+type dashboardServer struct {
+	layout *dashboardLayout
+	cache  *ttlCache
+	fetch  func(req *http.Request, username string) ([]byte, error)
+}
+
+func (d *dashboardServeCommand) Execute(args []string) error {
+	layout, err := loadDashboardLayout(d.Layout)
+	if err != nil {
+		return err
+	}
+	if len(layout.Graphs) == 0 {
+		return fmt.Errorf("no graphs to show. please specify them in the layout file")
+	}
+
+	server := newDashboardServer(layout, d.CacheTTL)
+	log.Printf("serving dashboard on %s", d.Addr)
+	return http.ListenAndServe(d.Addr, server)
+}
+
+func loadDashboardLayout(path string) (*dashboardLayout, error) {
+	layout := &dashboardLayout{}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read layout file : %s", err)
+		}
+		err = json.Unmarshal(b, layout)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse layout file %s : %s", path, err)
+		}
+	}
+
+	username, err := getUsername("")
+	if err != nil && (len(layout.Graphs) == 0 || hasOwnGraph(layout.Graphs)) {
+		return nil, err
+	}
+
+	if len(layout.Graphs) == 0 {
+		refs, err := dashboardGraphRefs(username, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			layout.Graphs = append(layout.Graphs, dashboardWidget{Graph: ref.String()})
+		}
+	}
+
+	if layout.Title == "" {
+		layout.Title = "Pixela dashboard"
+	}
+	if layout.Columns < 1 {
+		layout.Columns = 2
+	}
+	for i := range layout.Graphs {
+		w := &layout.Graphs[i]
+		w.ref = parseGraphRef(w.Graph, username)
+		if w.Title == "" {
+			w.Title = w.ref.String()
+		}
+	}
+	return layout, nil
+}
+
+func hasOwnGraph(widgets []dashboardWidget) bool {
+	for _, w := range widgets {
+		if !strings.Contains(w.Graph, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+func newDashboardServer(layout *dashboardLayout, ttl time.Duration) *dashboardServer {
+	return &dashboardServer{
+		layout: layout,
+		cache:  &ttlCache{ttl: ttl, now: time.Now, entries: map[string]ttlCacheEntry{}},
+		fetch:  doUserRequest,
+	}
+}
+
+func (c *ttlCache) get(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.body, nil
+	}
+
+	b, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = ttlCacheEntry{body: b, expires: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return b, nil
+}
+
+func (s *dashboardServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == "/" {
+		s.serveIndex(w)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/graphs/") {
+		s.serveSVG(w, strings.TrimPrefix(r.URL.Path, "/graphs/"))
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// serveSVG only proxies the graphs in the layout, so that the server does not become an open proxy.
+func (s *dashboardServer) serveSVG(w http.ResponseWriter, path string) {
+	i, err := strconv.Atoi(strings.TrimSuffix(path, ".svg"))
+	if err != nil || i < 0 || i >= len(s.layout.Graphs) {
+		http.NotFound(w, nil)
+		return
+	}
+	widget := s.layout.Graphs[i]
+
+	url, err := generateSVGUrl(&graphSVGCommand{
+		Username:   widget.ref.Username,
+		ID:         widget.ref.ID,
+		Mode:       widget.Mode,
+		Appearance: widget.Appearance,
+	})
+	if err != nil {
+		log.Printf("Failed to generate svg url of %s : %s", widget.ref, err)
+		http.Error(w, "Failed to fetch graph", http.StatusInternalServerError)
+		return
+	}
+	// the graphs of other users are read with their token in the config file, so that their secret graphs are shown.
+	b, err := s.cache.get(url, func() ([]byte, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return s.fetch(req, widget.ref.Username)
+	})
+	if err != nil {
+		// the page may be public, so the detail of the error is only logged.
+		log.Printf("Failed to fetch svg of %s : %s", widget.ref, strings.TrimSpace(err.Error()))
+		http.Error(w, "Failed to fetch graph", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(b)
+}
+
+type dashboardCell struct {
+	Index int
+	Title string
+	Stats *graphStats
+	Error string
+}
+
+func (s *dashboardServer) serveIndex(w http.ResponseWriter) {
+	cells := make([]dashboardCell, len(s.layout.Graphs))
+	wg := &sync.WaitGroup{}
+	for i, widget := range s.layout.Graphs {
+		cells[i] = dashboardCell{Index: i, Title: widget.Title}
+		wg.Add(1)
+		go func(cell *dashboardCell, ref graphRef) {
+			defer wg.Done()
+			stats, err := s.stats(ref)
+			if err != nil {
+				log.Printf("Failed to fetch stats of %s : %s", ref, strings.TrimSpace(err.Error()))
+				cell.Error = "Failed to fetch stats"
+				return
+			}
+			cell.Stats = stats
+		}(&cells[i], widget.ref)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTemplate.Execute(w, map[string]interface{}{
+		"Layout": s.layout,
+		"Cells":  cells,
+	})
+	if err != nil {
+		log.Printf("Failed to render dashboard : %s", err)
+	}
+}
+
+func (s *dashboardServer) stats(ref graphRef) (*graphStats, error) {
+	req, err := generateGetGraphStatsRequest(&getGraphStatsCommand{Username: ref.Username, ID: ref.ID})
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.cache.get(req.URL.String(), func() ([]byte, error) {
+		return s.fetch(req, ref.Username)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &graphStats{}
+	err = json.Unmarshal(b, stats)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse stats : %s", err)
+	}
+	return stats, nil
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"quantity": func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Layout.Title}}</title>
+{{if .Layout.Refresh}}<meta http-equiv="refresh" content="{{.Layout.Refresh}}">{{end}}
+<style>
+body { font-family: sans-serif; margin: 16px; }
+.grid { display: grid; grid-template-columns: repeat({{.Layout.Columns}}, 1fr); gap: 16px; }
+.cell { border: 1px solid #ddd; border-radius: 4px; padding: 8px; }
+.cell img { width: 100%; }
+.stats { display: flex; gap: 16px; font-size: 0.9em; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>{{.Layout.Title}}</h1>
+<div class="grid">
+{{range .Cells}}<div class="cell">
+<h2>{{.Title}}</h2>
+<img src="/graphs/{{.Index}}.svg" alt="{{.Title}}">
+{{if .Stats}}<div class="stats">
+<span>today: {{quantity .Stats.TodaysQuantity}}</span>
+<span>total: {{quantity .Stats.TotalQuantity}}</span>
+<span>max: {{quantity .Stats.MaxQuantity}}</span>
+<span>avg: {{quantity .Stats.AvgQuantity}}</span>
+<span>pixels: {{.Stats.TotalPixelsCount}}</span>
+</div>{{else}}<div class="error">{{.Error}}</div>{{end}}
+</div>
+{{end}}</div>
+</body>
+</html>
+`))
@@ -0,0 +1,185 @@
+package pi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTTLCache(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := &ttlCache{ttl: time.Minute, now: func() time.Time { return now }, entries: map[string]ttlCacheEntry{}}
+	calls := 0
+	fetch := func() ([]byte, error) {
+		calls++
+		return []byte(fmt.Sprint(calls)), nil
+	}
+
+	b, _ := cache.get("key", fetch)
+	if string(b) != "1" {
+		t.Errorf("Unexpected value. %s", string(b))
+	}
+	now = now.Add(30 * time.Second)
+	b, _ = cache.get("key", fetch)
+	if string(b) != "1" {
+		t.Errorf("Unexpected value before expiration. %s", string(b))
+	}
+	now = now.Add(time.Minute)
+	b, _ = cache.get("key", fetch)
+	if string(b) != "2" {
+		t.Errorf("Unexpected value after expiration. %s", string(b))
+	}
+
+	_, err := cache.get("error", func() ([]byte, error) { return nil, fmt.Errorf("failed") })
+	if err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+	if _, ok := cache.entries["error"]; ok {
+		t.Errorf("error response should not be cached")
+	}
+}
+
+func TestLoadDashboardLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-layout")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "layout.json")
+	err = ioutil.WriteFile(path, []byte(`{"columns":3,"graphs":[{"graph":"a-know/test-id","title":"Commits"},{"graph":"c-know/other-id","mode":"short"}]}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write layout. %s", err)
+	}
+
+	layout, err := loadDashboardLayout(path)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if layout.Title != "Pixela dashboard" || layout.Columns != 3 || len(layout.Graphs) != 2 {
+		t.Errorf("Unexpected layout. %+v", layout)
+	}
+	if layout.Graphs[0].Title != "Commits" || layout.Graphs[1].Title != "c-know/other-id" {
+		t.Errorf("Unexpected widget titles. %+v", layout.Graphs)
+	}
+	if layout.Graphs[1].ref != (graphRef{Username: "c-know", ID: "other-id"}) {
+		t.Errorf("Unexpected widget graph. %+v", layout.Graphs[1].ref)
+	}
+}
+
+func TestDashboardServer(t *testing.T) {
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+	defer cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	layout := &dashboardLayout{
+		Title:   "Team",
+		Columns: 2,
+		Graphs: []dashboardWidget{
+			{Title: "Commits", Mode: "short", ref: graphRef{Username: "a-know", ID: "test-id"}},
+			{Title: "Broken", ref: graphRef{Username: "c-know", ID: "broken-id"}},
+		},
+	}
+	server := newDashboardServer(layout, time.Minute)
+	requested := []string{}
+	mu := &sync.Mutex{}
+	server.fetch = func(req *http.Request, username string) ([]byte, error) {
+		if !strings.Contains(req.URL.Path, "/users/"+username+"/") {
+			t.Errorf("Unexpected username %s for %s", username, req.URL)
+		}
+		if req.Header.Get("X-USER-TOKEN") != "" {
+			t.Errorf("token should not be sent. %s", req.URL)
+		}
+		mu.Lock()
+		requested = append(requested, req.URL.String())
+		mu.Unlock()
+		switch req.URL.String() {
+		case fmt.Sprintf("https://%s/v1/users/a-know/graphs/test-id/stats", afterAPIBaseEnv):
+			return []byte(`{"totalPixelsCount":3,"maxQuantity":5,"minQuantity":1,"totalQuantity":9,"avgQuantity":3,"todaysQuantity":2}`), nil
+		case fmt.Sprintf("https://%s/v1/users/a-know/graphs/test-id?mode=short", afterAPIBaseEnv):
+			return []byte(`<svg></svg>`), nil
+		}
+		return nil, fmt.Errorf(`{"message":"not found","isSuccess":false}`)
+	}
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK {
+		t.Errorf("Unexpected status. %d", rec.Code)
+	}
+	for _, want := range []string{"<title>Team</title>", "<h2>Commits</h2>", "total: 9", `<img src="/graphs/0.svg"`, "Failed to fetch stats"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Page does not contain %s. %s", want, body)
+		}
+	}
+	if strings.Contains(body, "not found") {
+		t.Errorf("the detail of the error should not be shown. %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/graphs/0.svg", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "<svg></svg>" || rec.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("Unexpected svg response. %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/graphs/0.svg", nil))
+	// the stats of broken graph is not cached.
+	if len(requested) != 4 {
+		t.Errorf("Unexpected requests. %v", requested)
+	}
+
+	for _, path := range []string{"/graphs/2.svg", "/graphs/x.svg", "/unknown"} {
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Unexpected status for %s. %d", path, rec.Code)
+		}
+	}
+}
+
+func TestDashboardServerWithTokens(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.tokens["a-know"] = "a-know-secret"
+	pixela.addGraph("a-know", graphDefinition{ID: "secret-id", Color: "shibafu", IsSecret: true})
+	pixela.setPixel("a-know/secret-id", "20190101", pixelBody{Quantity: "1"})
+
+	layout := &dashboardLayout{Title: "Team", Columns: 1, Graphs: []dashboardWidget{
+		{Title: "Secret", ref: graphRef{Username: "a-know", ID: "secret-id"}},
+	}}
+	get := func(server *dashboardServer, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	// the secret graph of other user can not be read without the token, and the error is not shown.
+	server := newDashboardServer(layout, time.Minute)
+	if rec := get(server, "/graphs/0.svg"); rec.Code != http.StatusBadGateway || strings.Contains(rec.Body.String(), "token") {
+		t.Errorf("Unexpected svg response. %d %s", rec.Code, rec.Body.String())
+	}
+	if body := get(server, "/").Body.String(); !strings.Contains(body, "Failed to fetch stats") || strings.Contains(body, "token") {
+		t.Errorf("Unexpected page. %s", body)
+	}
+
+	err := ioutil.WriteFile(pixela.configPath, []byte(`{"tokens":{"a-know":"a-know-secret"}}`), 0600)
+	if err != nil {
+		t.Fatalf("Failed to write config. %s", err)
+	}
+	server = newDashboardServer(layout, time.Minute)
+	if rec := get(server, "/graphs/0.svg"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "shibafu") {
+		t.Errorf("Unexpected svg response. %d %s", rec.Code, rec.Body.String())
+	}
+	if body := get(server, "/").Body.String(); !strings.Contains(body, "pixels: 1") {
+		t.Errorf("Unexpected page. %s", body)
+	}
+}
@@ -0,0 +1,141 @@
+package pi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var dashboardTests = []struct {
+	name     string
+	input    []string
+	exitCode int
+}{
+	{
+		name:     "dashboard - not specify username",
+		input:    []string{"dashboard", "--graph-id", "test-id"},
+		exitCode: 1,
+	},
+	{
+		name:     "dashboard - invalid days",
+		input:    []string{"dashboard", "--username", "c-know", "--graph-id", "test-id", "--days", "0"},
+		exitCode: 1,
+	},
+}
+
+func TestDashboard(t *testing.T) {
+	for _, tt := range dashboardTests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestDashboardGraphRefs(t *testing.T) {
+	refs, err := dashboardGraphRefs("c-know", []string{"test-id", "a-know/other-id"})
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	want := []graphRef{{Username: "c-know", ID: "test-id"}, {Username: "a-know", ID: "other-id"}}
+	if fmt.Sprint(refs) != fmt.Sprint(want) {
+		t.Errorf("Unexpected graph refs. %v", refs)
+	}
+}
+
+func TestSetUserToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-tokens")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{"tokens":{"a-know":"a-know-token"}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config. %s", err)
+	}
+	beforeConfigEnv := os.Getenv("PI_CONFIG")
+	os.Setenv("PI_CONFIG", path)
+	defer os.Setenv("PI_CONFIG", beforeConfigEnv)
+	beforeAPIBaseEnv, beforeTokenEnv, _, afterTokenEnv := prepare()
+	defer cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	tests := []struct {
+		username string
+		set      bool
+		token    string
+	}{
+		{"a-know", true, "a-know-token"},
+		{"c-know", false, afterTokenEnv},
+	}
+	for _, tt := range tests {
+		req, _ := generateGetGraphsRequest(&getGraphsCommand{Username: tt.username})
+		set, err := setUserToken(req, tt.username)
+		if err != nil {
+			t.Fatalf("Unexpected error occurs. %s", err)
+		}
+		if set != tt.set || req.Header.Get("X-USER-TOKEN") != tt.token {
+			t.Errorf("%s: set=%t token=%s", tt.username, set, req.Header.Get("X-USER-TOKEN"))
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   string
+	}{
+		{values: []float64{0, 1, 2, 3, 4, 5, 6, 7}, want: "▁▂▃▄▅▆▇█"},
+		{values: []float64{0, 0, 0}, want: "▁▁▁"},
+		{values: []float64{5, 10}, want: "▅█"},
+		{values: []float64{-7, 0}, want: "▁█"},
+		{values: []float64{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := sparkline(tt.values); got != tt.want {
+			t.Errorf("Unexpected sparkline for %v. got=%s want=%s", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeGraph(t *testing.T) {
+	today := time.Date(2019, 1, 31, 0, 0, 0, 0, time.UTC)
+	s := &graphSummary{
+		Ref:   graphRef{Username: "c-know", ID: "test-id"},
+		graph: &graphDefinition{ID: "test-id", Name: "test-name", Unit: "commits", Type: "int"},
+	}
+	pixels := []pixel{
+		{Date: "20181231", Quantity: "100"},
+		{Date: "20190101", Quantity: "3"},
+		{Date: "20190125", Quantity: "2"},
+		{Date: "20190129", Quantity: "7"},
+		{Date: "20190131", Quantity: "1"},
+	}
+
+	err := s.summarize(pixels, today, 5)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if s.Today != 1 || s.Week != 10 || s.Month != 10 {
+		t.Errorf("Unexpected summary. %+v", s)
+	}
+	if s.Sparkline != "▁▁█▁▂" {
+		t.Errorf("Unexpected sparkline. %s", s.Sparkline)
+	}
+
+	out := &bytes.Buffer{}
+	printGraphSummaries(out, []*graphSummary{s, {Ref: graphRef{Username: "c-know", ID: "missing"}, err: fmt.Errorf("not found")}})
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || strings.Join(strings.Fields(lines[1]), " ") != "c-know/test-id test-name commits 1 10 10 ▁▁█▁▂" || !strings.Contains(lines[2], "error: not found") {
+		t.Errorf("Unexpected output. %s", out.String())
+	}
+}
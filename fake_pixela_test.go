@@ -0,0 +1,258 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakePixela is an in-memory Pixela API for the tests which send requests.
+// Graphs and pixels are keyed by `<username>/<graph-id>`.
+type fakePixela struct {
+	mu       sync.Mutex
+	graphs   map[string]graphDefinition
+	pixels   map[string]map[string]pixelBody
+	rules    map[string][]notificationRule
+	tokens   map[string]string
+	requests []string
+
+	// noAdd makes add and subtract respond 404 as a server without those APIs.
+	// increment and decrement are always provided.
+	noAdd bool
+	// refuseBatch makes the batch API respond 403 as for a user who can not use it.
+	refuseBatch bool
+	// onGetPixel is called with the lock held after a pixel is read.
+	onGetPixel func(key string, date string)
+
+	server     *httptest.Server
+	configPath string
+}
+
+var fakePixelaEnvs = []string{
+	"PIXELA_API_BASE", "PIXELA_USER_TOKEN", "PIXELA_USER_NAME",
+	"PI_CONFIG", "PI_CACHE_DIR", "PI_CACHE_TTL", "PI_DATA_DIR", "PI_RATE_LIMIT",
+}
+
+// startFakePixela starts the server and points the api requests to it as `c-know` with the token `thisissecret`.
+// The returned function stops it and restores the environment.
+func startFakePixela() (*fakePixela, func()) {
+	f := &fakePixela{
+		graphs: map[string]graphDefinition{},
+		pixels: map[string]map[string]pixelBody{},
+		rules:  map[string][]notificationRule{},
+		tokens: map[string]string{"c-know": "thisissecret"},
+	}
+	f.server = httptest.NewTLSServer(f)
+
+	before := map[string]string{}
+	for _, env := range fakePixelaEnvs {
+		before[env] = os.Getenv(env)
+	}
+	beforeClient := httpClient
+	dir, err := ioutil.TempDir("", "pi-test")
+	if err != nil {
+		panic(err)
+	}
+	f.configPath = filepath.Join(dir, "config.json")
+
+	os.Setenv("PIXELA_API_BASE", strings.TrimPrefix(f.server.URL, "https://"))
+	os.Setenv("PIXELA_USER_TOKEN", "thisissecret")
+	os.Setenv("PIXELA_USER_NAME", "c-know")
+	os.Setenv("PI_CONFIG", f.configPath)
+	os.Setenv("PI_CACHE_DIR", filepath.Join(dir, "cache"))
+	os.Setenv("PI_CACHE_TTL", "")
+	os.Setenv("PI_DATA_DIR", filepath.Join(dir, "data"))
+	os.Setenv("PI_RATE_LIMIT", "")
+	httpClient = f.server.Client()
+
+	return f, func() {
+		f.server.Close()
+		httpClient = beforeClient
+		for env, value := range before {
+			os.Setenv(env, value)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func (f *fakePixela) addGraph(username string, def graphDefinition) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.graphs[username+"/"+def.ID] = def
+	f.pixels[username+"/"+def.ID] = map[string]pixelBody{}
+}
+
+func (f *fakePixela) setPixel(key string, date string, p pixelBody) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pixels[key][date] = p
+}
+
+// pixel returns the pixel and whether it is registered.
+func (f *fakePixela) pixel(key string, date string) (pixelBody, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.pixels[key][date]
+	return p, ok
+}
+
+// requested returns the requests as `<method> <path>`.
+func (f *fakePixela) requested() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.requests...)
+}
+
+var fakePixelaPath = regexp.MustCompile(`^/v1/users/([^/]+)/graphs(?:/([^/]+)(?:/([^/]+))?)?$`)
+var fakePixelaDate = regexp.MustCompile(`^\d{8}$`)
+
+func (f *fakePixela) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+
+	m := fakePixelaPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		f.fail(w, http.StatusNotFound, "Specified API is not found.")
+		return
+	}
+	username, id, action := m[1], m[2], m[3]
+	key := username + "/" + id
+	def, ok := f.graphs[key]
+	// the stats and the SVG of a graph are public unless the graph is secret.
+	public := ok && !def.IsSecret && r.Method == "GET" && (action == "stats" || action == "")
+	if !public && r.Header.Get("X-USER-TOKEN") != f.tokens[username] {
+		f.fail(w, http.StatusUnauthorized, "User `"+username+"` does not exist or the token is wrong.")
+		return
+	}
+	if id == "" {
+		defs := graphDefinitions{Graphs: []graphDefinition{}}
+		for key, def := range f.graphs {
+			if strings.HasPrefix(key, username+"/") {
+				defs.Graphs = append(defs.Graphs, def)
+			}
+		}
+		sort.Slice(defs.Graphs, func(i, j int) bool { return defs.Graphs[i].ID < defs.Graphs[j].ID })
+		f.respond(w, defs)
+		return
+	}
+	if !ok {
+		f.fail(w, http.StatusNotFound, "Specified graph not found.")
+		return
+	}
+
+	var body struct {
+		postPixelParam
+		Pixels []postPixelParam
+	}
+	if r.Method == "POST" && action == "pixels" {
+		json.NewDecoder(r.Body).Decode(&body.Pixels)
+	} else if r.Method == "POST" || r.Method == "PUT" {
+		json.NewDecoder(r.Body).Decode(&body.postPixelParam)
+	}
+
+	switch {
+	case r.Method == "GET" && action == "":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"><rect width="10" height="10" fill="%s"/></svg>`, def.Color)
+	case r.Method == "GET" && action == "graph-def":
+		f.respond(w, def)
+	case r.Method == "POST" && action == "":
+		f.pixels[key][body.Date] = pixelBody{Quantity: body.Quantity, OptionalData: body.OptionalData}
+		f.succeed(w)
+	case r.Method == "GET" && action == "pixels":
+		from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		pixels := pixelsWithBody{Pixels: []pixel{}}
+		for date, p := range f.pixels[key] {
+			if (from == "" || date >= from) && (to == "" || date <= to) {
+				pixels.Pixels = append(pixels.Pixels, pixel{Date: date, Quantity: p.Quantity, OptionalData: p.OptionalData})
+			}
+		}
+		f.respond(w, pixels)
+	case r.Method == "POST" && action == "pixels":
+		if f.refuseBatch {
+			f.fail(w, http.StatusForbidden, "This API is only available to Pixela supporters.")
+			return
+		}
+		for _, p := range body.Pixels {
+			f.pixels[key][p.Date] = pixelBody{Quantity: p.Quantity, OptionalData: p.OptionalData}
+		}
+		f.succeed(w)
+	case r.Method == "GET" && action == "notifications":
+		f.respond(w, notificationRules{Notifications: append([]notificationRule{}, f.rules[key]...)})
+	case r.Method == "GET" && action == "stats":
+		f.respond(w, map[string]interface{}{"totalPixelsCount": len(f.pixels[key])})
+	case r.Method == "PUT" && (action == "add" || action == "subtract" || action == "increment" || action == "decrement"):
+		if f.noAdd && (action == "add" || action == "subtract") {
+			f.fail(w, http.StatusNotFound, "Specified API is not found.")
+			return
+		}
+		today, _ := def.today()
+		date := today.Format(pixelDateLayout)
+		delta, _ := strconv.ParseFloat(body.Quantity, 64)
+		if action == "increment" || action == "decrement" {
+			delta = 1
+		}
+		if action == "subtract" || action == "decrement" {
+			delta = -delta
+		}
+		current, _ := strconv.ParseFloat(f.pixels[key][date].Quantity, 64)
+		f.pixels[key][date] = pixelBody{Quantity: def.formatQuantity(current + delta), OptionalData: f.pixels[key][date].OptionalData}
+		f.succeed(w)
+	case fakePixelaDate.MatchString(action):
+		f.servePixel(w, r.Method, key, action, body.postPixelParam)
+	default:
+		f.fail(w, http.StatusNotFound, "Specified API is not found.")
+	}
+}
+
+func (f *fakePixela) servePixel(w http.ResponseWriter, method string, key string, date string, body postPixelParam) {
+	p, ok := f.pixels[key][date]
+	switch method {
+	case "GET":
+		if !ok {
+			f.fail(w, http.StatusNotFound, "Specified pixel not found.")
+			return
+		}
+		if f.onGetPixel != nil {
+			f.onGetPixel(key, date)
+		}
+		f.respond(w, p)
+	case "PUT":
+		f.pixels[key][date] = pixelBody{Quantity: body.Quantity, OptionalData: body.OptionalData}
+		f.succeed(w)
+	case "DELETE":
+		if !ok {
+			f.fail(w, http.StatusNotFound, "Specified pixel not found.")
+			return
+		}
+		delete(f.pixels[key], date)
+		f.succeed(w)
+	default:
+		f.fail(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	}
+}
+
+func (f *fakePixela) respond(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakePixela) succeed(w http.ResponseWriter) {
+	f.respond(w, map[string]interface{}{"message": "Success.", "isSuccess": true})
+}
+
+func (f *fakePixela) fail(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": message, "isSuccess": false})
+}
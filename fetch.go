@@ -0,0 +1,291 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const pixelDateLayout = "20060102"
+
+type graphDefinition struct {
+	ID                  string   `json:"id"`
+	Name                string   `json:"name"`
+	Unit                string   `json:"unit"`
+	Type                string   `json:"type"`
+	Color               string   `json:"color"`
+	Timezone            string   `json:"timezone"`
+	PurgeCacheURLs      []string `json:"purgeCacheURLs"`
+	SelfSufficient      string   `json:"selfSufficient"`
+	IsSecret            bool     `json:"isSecret"`
+	PublishOptionalData bool     `json:"publishOptionalData"`
+}
+
+type graphDefinitions struct {
+	Graphs []graphDefinition `json:"graphs"`
+}
+
+type pixel struct {
+	Date         string `json:"date"`
+	Quantity     string `json:"quantity"`
+	OptionalData string `json:"optionalData,omitempty"`
+}
+
+type pixelsWithBody struct {
+	Pixels []pixel `json:"pixels"`
+}
+
+// location returns the timezone of the graph. Pixela treats an empty timezone as UTC.
+func (gD *graphDefinition) location() (*time.Location, error) {
+	if gD.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(gD.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load timezone `%s` of graph `%s` : %s", gD.Timezone, gD.ID, err)
+	}
+	return loc, nil
+}
+
+// today returns the current date of the graph's timezone.
+func (gD *graphDefinition) today() (time.Time, error) {
+	loc, err := gD.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), nil
+}
+
+// formatQuantity formats `v` so that Pixela accepts it as a quantity of the graph.
+// Float values are rounded to drop the error accumulated by arithmetic.
+func (gD *graphDefinition) formatQuantity(v float64) string {
+	if gD.Type == "int" {
+		return strconv.FormatInt(int64(math.Round(v)), 10)
+	}
+	return formatNumber(v)
+}
+
+// formatNumber formats `v` without the error accumulated by arithmetic.
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(roundNumber(v), 'f', -1, 64)
+}
+
+func roundNumber(v float64) float64 {
+	return math.Round(v*1e9) / 1e9
+}
+
+// doUserRequest sends a request to read the graphs of `username`. PIXELA_USER_TOKEN is valid only for its own user,
+// so the token of another user is taken from `tokens` of the config file.
+func doUserRequest(req *http.Request, username string) ([]byte, error) {
+	ok, err := setUserToken(req, username)
+	if err != nil {
+		return nil, err
+	}
+	b, err := doRequestAndGetBody(req)
+	if _, isAPIError := err.(*apiError); isAPIError && !ok && isOtherUser(username) {
+		return nil, fmt.Errorf("Failed to read the graphs of `%s` : %s\nPIXELA_USER_TOKEN is valid only for %s. set the token of `%s` in `tokens` of the config file to read the graphs of other users",
+			username, strings.TrimSpace(err.Error()), os.Getenv("PIXELA_USER_NAME"), username)
+	}
+	return b, err
+}
+
+// setUserToken sets the token of `username` in the config file to the request, and reports whether it is set.
+func setUserToken(req *http.Request, username string) (bool, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return false, err
+	}
+	token, ok := config.Tokens[username]
+	if !ok {
+		return false, nil
+	}
+	req.Header.Set("X-USER-TOKEN", token)
+	return true, nil
+}
+
+func isOtherUser(username string) bool {
+	own := os.Getenv("PIXELA_USER_NAME")
+	return own != "" && username != own
+}
+
+func fetchGraphDefinitions(username string) ([]graphDefinition, error) {
+	req, err := generateGetGraphsRequest(&getGraphsCommand{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := doUserRequest(req, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var defs graphDefinitions
+	err = json.Unmarshal(b, &defs)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse graph definitions : %s", err)
+	}
+	return defs.Graphs, nil
+}
+
+func fetchGraphDefinition(username string, id string) (*graphDefinition, error) {
+	req, err := generateGetGraphDefRequest(&getGraphDefCommand{Username: username, ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := doUserRequest(req, username)
+	if err != nil {
+		return nil, err
+	}
+
+	def := &graphDefinition{}
+	err = json.Unmarshal(b, def)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse graph definition : %s", err)
+	}
+	return def, nil
+}
+
+// fetchGraphPixels returns the pixels of the graph with their quantity and optionalData, sorted by date.
+func fetchGraphPixels(username string, id string, from string, to string) ([]pixel, error) {
+	req, err := generateGetGraphPixelsRequest(&getGraphPixelsCommand{
+		Username: username,
+		ID:       id,
+		From:     from,
+		To:       to,
+		WithBody: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := doUserRequest(req, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var pixels pixelsWithBody
+	err = json.Unmarshal(b, &pixels)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse pixels : %s", err)
+	}
+	sort.Slice(pixels.Pixels, func(i, j int) bool {
+		return pixels.Pixels[i].Date < pixels.Pixels[j].Date
+	})
+	return pixels.Pixels, nil
+}
+
+// pixelWindowDays is the longest period fetched by a request of fetchGraphPixelsInWindows.
+const pixelWindowDays = 365
+
+// fetchGraphPixelsInWindows fetches the pixels of a period which may be longer than a year by a request per year,
+// so that the period is not cut short by the API.
+func fetchGraphPixelsInWindows(username string, id string, from string, to string) ([]pixel, error) {
+	windows, err := pixelWindows(from, to)
+	if err != nil {
+		return nil, err
+	}
+	pixels := []pixel{}
+	for _, w := range windows {
+		p, err := fetchGraphPixels(username, id, w[0], w[1])
+		if err != nil {
+			return nil, err
+		}
+		pixels = append(pixels, p...)
+	}
+	return pixels, nil
+}
+
+// pixelPeriod validates the period in yyyyMMdd format. Like the API, it defaults to the year up to today.
+func pixelPeriod(today time.Time, from string, to string) (string, string, error) {
+	if from == "" {
+		from = today.AddDate(0, 0, -(pixelWindowDays - 1)).Format(pixelDateLayout)
+	}
+	if to == "" {
+		to = today.Format(pixelDateLayout)
+	}
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse(pixelDateLayout, d); err != nil {
+			return "", "", fmt.Errorf("invalid date `%s`", d)
+		}
+	}
+	if to < from {
+		return "", "", fmt.Errorf("--to must not be before %s", from)
+	}
+	return from, to, nil
+}
+
+// graphPixelPeriod is pixelPeriod up to `to`, which defaults to today in the graph's timezone.
+func graphPixelPeriod(def *graphDefinition, from string, to string) (string, string, error) {
+	end, err := def.today()
+	if err != nil {
+		return "", "", err
+	}
+	if to != "" {
+		end, err = time.Parse(pixelDateLayout, to)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid date `%s`", to)
+		}
+	}
+	return pixelPeriod(end, from, to)
+}
+
+// pixelWindows splits the period into the periods of pixelWindowDays days at most, in ascending order.
+func pixelWindows(from string, to string) ([][2]string, error) {
+	start, err := time.Parse(pixelDateLayout, from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date `%s`", from)
+	}
+	end, err := time.Parse(pixelDateLayout, to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date `%s`", to)
+	}
+	windows := [][2]string{}
+	for !start.After(end) {
+		last := start.AddDate(0, 0, pixelWindowDays-1)
+		if last.After(end) {
+			last = end
+		}
+		windows = append(windows, [2]string{start.Format(pixelDateLayout), last.Format(pixelDateLayout)})
+		start = last.AddDate(0, 0, 1)
+	}
+	return windows, nil
+}
+
+// pixelQuantities converts pixels into a map from date (yyyyMMdd) to quantity.
+func pixelQuantities(pixels []pixel) (map[string]float64, error) {
+	quantities := make(map[string]float64, len(pixels))
+	for _, p := range pixels {
+		q, err := strconv.ParseFloat(p.Quantity, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity `%s` on %s", p.Quantity, p.Date)
+		}
+		quantities[p.Date] = q
+	}
+	return quantities, nil
+}
+
+// graphRef identifies a graph which may belong to another user.
+type graphRef struct {
+	Username string
+	ID       string
+}
+
+func (r graphRef) String() string {
+	return fmt.Sprintf("%s/%s", r.Username, r.ID)
+}
+
+// parseGraphRef parses `<username>/<graph-id>` or `<graph-id>`.
+func parseGraphRef(s string, defaultUsername string) graphRef {
+	if i := strings.Index(s, "/"); i >= 0 {
+		return graphRef{Username: s[:i], ID: s[i+1:]}
+	}
+	return graphRef{Username: defaultUsername, ID: s}
+}
@@ -0,0 +1,422 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type goalsCommand struct {
+	Set    setGoalCommand    `description:"set a goal of Graph" command:"set" subcommands-optional:"true"`
+	Delete deleteGoalCommand `description:"delete a goal of Graph" command:"delete" subcommands-optional:"true"`
+	Status goalStatusCommand `description:"show progress of goals" command:"status" subcommands-optional:"true"`
+}
+
+type setGoalCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	Daily    string `long:"daily" description:"The quantity to record every day."`
+	Total    string `long:"total" description:"The total quantity to record by the date of --by."`
+	By       string `long:"by" description:"The last day of the total goal in yyyyMMdd format."`
+	Since    string `long:"since" description:"The first day of the total goal in yyyyMMdd format. Defaults to today in the graph's timezone."`
+}
+
+type deleteGoalCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+}
+
+type goalStatusCommand struct {
+	ID     string `short:"g" long:"graph-id" description:"Show only the goal of this graph."`
+	Format string `long:"format" description:"Output format." choice:"table" choice:"json" default:"table"`
+	Color  string `long:"color" description:"Color the table. auto colors it when the output is a terminal and NO_COLOR is not set." choice:"auto" choice:"always" choice:"never" default:"auto"`
+}
+
+type goal struct {
+	Username string  `json:"username"`
+	GraphID  string  `json:"graphID"`
+	Daily    float64 `json:"daily,omitempty"`
+	Total    float64 `json:"total,omitempty"`
+	Since    string  `json:"since,omitempty"`
+	By       string  `json:"by,omitempty"`
+}
+
+type goalState struct {
+	Goals []goal `json:"goals"`
+}
+
+// goalStatus is the progress of a goal. For a daily goal, the current quantity is today's one,
+// and the current pace is the average of the recent days.
+type goalStatus struct {
+	Graph        string  `json:"graph"`
+	Kind         string  `json:"kind"`
+	Target       float64 `json:"target"`
+	Current      float64 `json:"current"`
+	Progress     float64 `json:"progress"`
+	Remaining    float64 `json:"remaining"`
+	By           string  `json:"by,omitempty"`
+	DaysLeft     int     `json:"daysLeft"`
+	RequiredPace float64 `json:"requiredPace"`
+	CurrentPace  float64 `json:"currentPace"`
+	Projected    float64 `json:"projected"`
+	State        string  `json:"state"`
+}
+
+const (
+	goalAchieved = "achieved"
+	goalOnTrack  = "on track"
+	goalBehind   = "behind"
+	goalMissed   = "missed"
+
+	// goalPaceDays is the number of recent days averaged for the pace of a daily goal.
+	goalPaceDays = 7
+)
+
+var goalColors = map[string]string{
+	goalAchieved: "\x1b[32m",
+	goalOnTrack:  "\x1b[36m",
+	goalBehind:   "\x1b[33m",
+	goalMissed:   "\x1b[31m",
+}
+
+const goalResetColor = "\x1b[0m"
+
+// goalToday returns today in the timezone of the graph, which is the default first day of a total goal.
+var goalToday = func(username string, id string) (time.Time, error) {
+	def, err := fetchGraphDefinition(username, id)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return def.today()
+}
+
+func (sG *setGoalCommand) Execute(args []string) error {
+	username, err := getUsername(sG.Username)
+	if err != nil {
+		return err
+	}
+	g, err := newGoal(sG, username)
+	if err != nil {
+		return err
+	}
+
+	state, err := loadGoalState()
+	if err != nil {
+		return err
+	}
+	if i, ok := state.find(username, sG.ID); ok {
+		state.Goals[i] = *g
+	} else {
+		state.Goals = append(state.Goals, *g)
+	}
+	err = saveGoalState(state)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("set the goal of %s/%s : %s\n", username, sG.ID, g)
+	return nil
+}
+
+func newGoal(sG *setGoalCommand, username string) (*goal, error) {
+	if (sG.Daily == "") == (sG.Total == "") {
+		return nil, fmt.Errorf("specify either --daily or --total")
+	}
+	g := &goal{Username: username, GraphID: sG.ID}
+
+	if sG.Daily != "" {
+		if sG.By != "" || sG.Since != "" {
+			return nil, fmt.Errorf("--by and --since are available only with --total")
+		}
+		v, err := strconv.ParseFloat(sG.Daily, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid daily goal `%s`", sG.Daily)
+		}
+		g.Daily = v
+		return g, nil
+	}
+
+	v, err := strconv.ParseFloat(sG.Total, 64)
+	if err != nil || v <= 0 {
+		return nil, fmt.Errorf("invalid total goal `%s`", sG.Total)
+	}
+	g.Total = v
+	if sG.By == "" {
+		return nil, fmt.Errorf("--by is required with --total")
+	}
+	by, err := time.Parse(pixelDateLayout, sG.By)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date `%s`", sG.By)
+	}
+	g.By = sG.By
+	g.Since = sG.Since
+	if g.Since == "" {
+		today, err := goalToday(username, sG.ID)
+		if err != nil {
+			return nil, err
+		}
+		g.Since = today.Format(pixelDateLayout)
+	}
+	since, err := time.Parse(pixelDateLayout, g.Since)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date `%s`", g.Since)
+	}
+	if by.Before(since) {
+		return nil, fmt.Errorf("--by must not be before %s", g.Since)
+	}
+	return g, nil
+}
+
+func (g *goal) String() string {
+	if g.Daily > 0 {
+		return fmt.Sprintf("%s a day", formatNumber(g.Daily))
+	}
+	return fmt.Sprintf("%s from %s by %s", formatNumber(g.Total), g.Since, g.By)
+}
+
+func (dG *deleteGoalCommand) Execute(args []string) error {
+	username, err := getUsername(dG.Username)
+	if err != nil {
+		return err
+	}
+	state, err := loadGoalState()
+	if err != nil {
+		return err
+	}
+	i, ok := state.find(username, dG.ID)
+	if !ok {
+		return fmt.Errorf("the goal of graph `%s` is not set", dG.ID)
+	}
+	state.Goals = append(state.Goals[:i], state.Goals[i+1:]...)
+	err = saveGoalState(state)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("deleted the goal of %s/%s\n", username, dG.ID)
+	return nil
+}
+
+func (gS *goalStatusCommand) Execute(args []string) error {
+	state, err := loadGoalState()
+	if err != nil {
+		return err
+	}
+
+	// a goal which fails is reported, and the others are still shown.
+	statuses := []*goalStatus{}
+	goals, failed := 0, 0
+	for _, g := range state.Goals {
+		if gS.ID != "" && g.GraphID != gS.ID {
+			continue
+		}
+		goals++
+		s, err := fetchGoalStatus(g)
+		if err != nil {
+			failed++
+			log.Printf("warning: failed to fetch the status of the goal of %s : %s", graphRef{Username: g.Username, ID: g.GraphID}, strings.TrimSpace(err.Error()))
+			continue
+		}
+		statuses = append(statuses, s)
+	}
+
+	if gS.Format == "json" {
+		err = json.NewEncoder(os.Stdout).Encode(statuses)
+		if err != nil {
+			return err
+		}
+	} else if goals == 0 {
+		fmt.Println("no goals are set")
+	} else if len(statuses) > 0 {
+		printGoalStatuses(os.Stdout, statuses, useColor(gS.Color, os.Stdout))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d goals failed", failed, goals)
+	}
+	return nil
+}
+
+// fetchGoalStatus fetches the pixels of the period of the goal up to today in the graph's timezone.
+func fetchGoalStatus(g goal) (*goalStatus, error) {
+	def, err := fetchGraphDefinition(g.Username, g.GraphID)
+	if err != nil {
+		return nil, err
+	}
+	today, err := def.today()
+	if err != nil {
+		return nil, err
+	}
+
+	from := today.AddDate(0, 0, -(goalPaceDays - 1)).Format(pixelDateLayout)
+	to := today.Format(pixelDateLayout)
+	if g.Daily == 0 {
+		from = g.Since
+		if g.By < to {
+			to = g.By
+		}
+	}
+	if from > to {
+		return computeGoalStatus(g, nil, today)
+	}
+	// a total goal may be longer than a year.
+	pixels, err := fetchGraphPixelsInWindows(g.Username, g.GraphID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return computeGoalStatus(g, pixels, today)
+}
+
+func computeGoalStatus(g goal, pixels []pixel, today time.Time) (*goalStatus, error) {
+	quantities, err := pixelQuantities(pixels)
+	if err != nil {
+		return nil, err
+	}
+	s := &goalStatus{Graph: graphRef{Username: g.Username, ID: g.GraphID}.String()}
+
+	if g.Daily > 0 {
+		s.Kind = "daily"
+		s.Target = g.Daily
+		s.Current = quantities[today.Format(pixelDateLayout)]
+		sum := 0.0
+		for i := 0; i < goalPaceDays; i++ {
+			sum += quantities[today.AddDate(0, 0, -i).Format(pixelDateLayout)]
+		}
+		s.RequiredPace = g.Daily
+		s.CurrentPace = sum / goalPaceDays
+		s.Projected = math.Max(s.Current, s.CurrentPace)
+	} else {
+		s.Kind = "total"
+		s.Target = g.Total
+		s.By = g.By
+		since, err := time.ParseInLocation(pixelDateLayout, g.Since, today.Location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid date `%s`", g.Since)
+		}
+		by, err := time.ParseInLocation(pixelDateLayout, g.By, today.Location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid date `%s`", g.By)
+		}
+		for date, q := range quantities {
+			if date >= g.Since && date <= g.By {
+				s.Current += q
+			}
+		}
+
+		// today is counted as an elapsed day, so the pace is the one required from tomorrow.
+		elapsed := daysBetween(since, today) + 1
+		if elapsed > 0 {
+			s.CurrentPace = s.Current / float64(elapsed)
+		}
+		if daysLeft := daysBetween(today, by); daysLeft > 0 {
+			s.DaysLeft = daysLeft
+		}
+		remaining := math.Max(0, g.Total-s.Current)
+		s.RequiredPace = remaining
+		if s.DaysLeft > 0 {
+			s.RequiredPace = remaining / float64(s.DaysLeft)
+		}
+		s.Projected = s.Current + s.CurrentPace*float64(s.DaysLeft)
+		if today.After(by) && s.Current < g.Total {
+			s.State = goalMissed
+		}
+	}
+
+	s.Remaining = math.Max(0, s.Target-s.Current)
+	s.Progress = s.Current / s.Target
+	if s.State == "" {
+		switch {
+		case s.Current >= s.Target:
+			s.State = goalAchieved
+		case s.Projected >= s.Target:
+			s.State = goalOnTrack
+		default:
+			s.State = goalBehind
+		}
+	}
+
+	s.Current = roundNumber(s.Current)
+	s.Progress = roundNumber(s.Progress)
+	s.Remaining = roundNumber(s.Remaining)
+	s.RequiredPace = roundNumber(s.RequiredPace)
+	s.CurrentPace = roundNumber(s.CurrentPace)
+	s.Projected = roundNumber(s.Projected)
+	return s, nil
+}
+
+func daysBetween(from time.Time, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+// useColor decides whether to color the output by `--color`.
+func useColor(mode string, out *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := out.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func printGoalStatuses(out io.Writer, statuses []*goalStatus, color bool) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "graph\tgoal\tprogress\tcurrent\tremaining\trequired pace\tcurrent pace\tprojected\tstate")
+	for _, s := range statuses {
+		target := fmt.Sprintf("%s a day", formatNumber(s.Target))
+		if s.Kind == "total" {
+			target = fmt.Sprintf("%s by %s", formatNumber(s.Target), s.By)
+		}
+		state := s.State
+		if s.Kind == "total" && s.DaysLeft > 0 {
+			state = fmt.Sprintf("%s (%d days left)", s.State, s.DaysLeft)
+		}
+		// the state is the last column, so its escape sequences do not break the alignment.
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s/day\t%s/day\t%s\t%s\n",
+			s.Graph, target, progressBar(s.Progress, 10), formatNumber(s.Current), formatNumber(s.Remaining),
+			formatNumber(s.RequiredPace), formatNumber(s.CurrentPace), formatNumber(s.Projected), colorize(state, goalColors[s.State], color))
+	}
+	w.Flush()
+}
+
+func progressBar(progress float64, width int) string {
+	filled := int(math.Min(1, math.Max(0, progress)) * float64(width))
+	return fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("#", filled), strings.Repeat("-", width-filled), int(math.Floor(progress*100)))
+}
+
+func colorize(s string, color string, enabled bool) string {
+	if !enabled || color == "" {
+		return s
+	}
+	return color + s + goalResetColor
+}
+
+func (s *goalState) find(username string, id string) (int, bool) {
+	for i, g := range s.Goals {
+		if g.Username == username && g.GraphID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func loadGoalState() (*goalState, error) {
+	state := &goalState{}
+	err := loadDataFile("goals.json", "goals", state)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func saveGoalState(state *goalState) error {
+	return saveDataFile("goals.json", "goals", state)
+}
@@ -0,0 +1,230 @@
+package pi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGoals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-goals")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	beforeDataDirEnv := os.Getenv("PI_DATA_DIR")
+	os.Setenv("PI_DATA_DIR", dir)
+	defer os.Setenv("PI_DATA_DIR", beforeDataDirEnv)
+
+	tests := []struct {
+		name     string
+		input    []string
+		exitCode int
+	}{
+		{"set goal - not specify graph-id", []string{"goals", "set", "--username", "c-know", "--daily", "30"}, 1},
+		{"set goal - not specify goal", []string{"goals", "set", "--username", "c-know", "--graph-id", "reading"}, 1},
+		{"set goal - both daily and total", []string{"goals", "set", "--username", "c-know", "--graph-id", "reading", "--daily", "30", "--total", "200", "--by", "20261231"}, 1},
+		{"set goal - total without by", []string{"goals", "set", "--username", "c-know", "--graph-id", "commits", "--total", "200"}, 1},
+		{"set goal - by before since", []string{"goals", "set", "--username", "c-know", "--graph-id", "commits", "--total", "200", "--since", "20261001", "--by", "20260930"}, 1},
+		{"set goal - invalid daily", []string{"goals", "set", "--username", "c-know", "--graph-id", "reading", "--daily", "-1"}, 1},
+		{"status - no goals", []string{"goals", "status"}, 0},
+		{"set daily goal", []string{"goals", "set", "--username", "c-know", "--graph-id", "reading", "--daily", "30"}, 0},
+		{"set total goal", []string{"goals", "set", "--username", "c-know", "--graph-id", "commits", "--total", "200", "--since", "20261001", "--by", "20261231"}, 0},
+		{"update goal", []string{"goals", "set", "--username", "c-know", "--graph-id", "reading", "--daily", "45"}, 0},
+		{"status - invalid color", []string{"goals", "status", "--color", "rainbow"}, 1},
+		{"delete goal", []string{"goals", "delete", "--username", "c-know", "--graph-id", "commits"}, 0},
+		{"delete goal - not set", []string{"goals", "delete", "--username", "c-know", "--graph-id", "commits"}, 1},
+	}
+	for _, tt := range tests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+
+	state, err := loadGoalState()
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if len(state.Goals) != 1 || state.Goals[0] != (goal{Username: "c-know", GraphID: "reading", Daily: 45}) {
+		t.Errorf("Unexpected goals. %+v", state.Goals)
+	}
+}
+
+func TestGoalStatusWithFailure(t *testing.T) {
+	f, stop := startFakePixela()
+	defer stop()
+	f.addGraph("c-know", graphDefinition{ID: "reading", Type: "int"})
+
+	err := saveGoalState(&goalState{Goals: []goal{
+		{Username: "c-know", GraphID: "missing", Daily: 10},
+		{Username: "c-know", GraphID: "reading", Daily: 30},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+
+	errStream := &bytes.Buffer{}
+	exitCode := (&CLI{
+		ErrStream: errStream,
+		OutStream: ioutil.Discard,
+	}).Run([]string{"goals", "status", "--format", "json"})
+	if exitCode != 1 {
+		t.Errorf("Unexpected exit code. %d", exitCode)
+	}
+	if !strings.Contains(errStream.String(), "c-know/missing") {
+		t.Errorf("The failed goal is not reported. %s", errStream.String())
+	}
+	// the goal after the failed one is still fetched.
+	fetched := false
+	for _, r := range f.requested() {
+		if strings.HasPrefix(r, "GET /v1/users/c-know/graphs/reading/pixels") {
+			fetched = true
+		}
+	}
+	if !fetched {
+		t.Errorf("The pixels of the other goal are not fetched. %v", f.requested())
+	}
+}
+
+func TestNewGoalSince(t *testing.T) {
+	beforeGoalToday := goalToday
+	defer func() { goalToday = beforeGoalToday }()
+	goalToday = func(username string, id string) (time.Time, error) {
+		loc := time.FixedZone("JST", 9*60*60)
+		return time.Date(2019, 1, 2, 0, 0, 0, 0, loc), nil
+	}
+
+	g, err := newGoal(&setGoalCommand{ID: "commits", Total: "200", By: "20191231"}, "c-know")
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if g.Since != "20190102" {
+		t.Errorf("since should be today of the graph. %s", g.Since)
+	}
+}
+
+func TestPixelWindows(t *testing.T) {
+	windows, err := pixelWindows("20180101", "20191231")
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	want := [][2]string{{"20180101", "20181231"}, {"20190101", "20191231"}}
+	if !reflect.DeepEqual(windows, want) {
+		t.Errorf("out=%v want=%v", windows, want)
+	}
+
+	windows, _ = pixelWindows("20190101", "20190101")
+	if !reflect.DeepEqual(windows, [][2]string{{"20190101", "20190101"}}) {
+		t.Errorf("Unexpected windows. %v", windows)
+	}
+	windows, _ = pixelWindows("20190102", "20190101")
+	if len(windows) != 0 {
+		t.Errorf("Unexpected windows. %v", windows)
+	}
+}
+
+func TestComputeGoalStatus(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse(pixelDateLayout, s)
+		return d
+	}
+	recent := []pixel{}
+	for _, d := range []string{"20190104", "20190105", "20190106", "20190107", "20190108", "20190109"} {
+		recent = append(recent, pixel{Date: d, Quantity: "35"})
+	}
+
+	tests := []struct {
+		name   string
+		goal   goal
+		pixels []pixel
+		today  string
+		want   goalStatus
+	}{
+		{
+			name:   "daily goal on track",
+			goal:   goal{Username: "c-know", GraphID: "reading", Daily: 30},
+			pixels: append(recent, pixel{Date: "20190110", Quantity: "20"}),
+			today:  "20190110",
+			want:   goalStatus{Graph: "c-know/reading", Kind: "daily", Target: 30, Current: 20, Progress: 0.666666667, Remaining: 10, RequiredPace: 30, CurrentPace: 32.857142857, Projected: 32.857142857, State: goalOnTrack},
+		},
+		{
+			name:   "daily goal achieved",
+			goal:   goal{Username: "c-know", GraphID: "reading", Daily: 30},
+			pixels: []pixel{{Date: "20190110", Quantity: "30"}},
+			today:  "20190110",
+			want:   goalStatus{Graph: "c-know/reading", Kind: "daily", Target: 30, Current: 30, Progress: 1, RequiredPace: 30, CurrentPace: 4.285714286, Projected: 30, State: goalAchieved},
+		},
+		{
+			name:  "total goal behind",
+			goal:  goal{Username: "c-know", GraphID: "commits", Total: 200, Since: "20190101", By: "20190131"},
+			today: "20190110",
+			pixels: []pixel{
+				{Date: "20181231", Quantity: "100"},
+				{Date: "20190101", Quantity: "20"},
+				{Date: "20190110", Quantity: "30"},
+			},
+			want: goalStatus{Graph: "c-know/commits", Kind: "total", Target: 200, Current: 50, Progress: 0.25, Remaining: 150, By: "20190131", DaysLeft: 21, RequiredPace: 7.142857143, CurrentPace: 5, Projected: 155, State: goalBehind},
+		},
+		{
+			name:   "total goal on track",
+			goal:   goal{Username: "c-know", GraphID: "commits", Total: 200, Since: "20190101", By: "20190131"},
+			today:  "20190110",
+			pixels: []pixel{{Date: "20190105", Quantity: "100"}},
+			want:   goalStatus{Graph: "c-know/commits", Kind: "total", Target: 200, Current: 100, Progress: 0.5, Remaining: 100, By: "20190131", DaysLeft: 21, RequiredPace: 4.761904762, CurrentPace: 10, Projected: 310, State: goalOnTrack},
+		},
+		{
+			name:   "total goal missed",
+			goal:   goal{Username: "c-know", GraphID: "commits", Total: 200, Since: "20190101", By: "20190131"},
+			today:  "20190201",
+			pixels: []pixel{{Date: "20190105", Quantity: "100"}},
+			want:   goalStatus{Graph: "c-know/commits", Kind: "total", Target: 200, Current: 100, Progress: 0.5, Remaining: 100, By: "20190131", RequiredPace: 100, CurrentPace: 3.125, Projected: 100, State: goalMissed},
+		},
+	}
+	for _, tt := range tests {
+		got, err := computeGoalStatus(tt.goal, tt.pixels, date(tt.today))
+		if err != nil {
+			t.Errorf("%s: unexpected error. %s", tt.name, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("%s:\n out=%+v\nwant=%+v", tt.name, *got, tt.want)
+		}
+	}
+}
+
+func TestPrintGoalStatuses(t *testing.T) {
+	statuses := []*goalStatus{
+		{Graph: "c-know/reading", Kind: "daily", Target: 30, Current: 15, Progress: 0.5, Remaining: 15, RequiredPace: 30, CurrentPace: 20, Projected: 20, State: goalBehind},
+		{Graph: "c-know/commits", Kind: "total", Target: 200, Current: 200, Progress: 1, By: "20261231", DaysLeft: 3, CurrentPace: 4, Projected: 212, State: goalAchieved},
+	}
+
+	out := &bytes.Buffer{}
+	printGoalStatuses(out, statuses, false)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Unexpected output. %s", out.String())
+	}
+	if got := strings.Join(strings.Fields(lines[1]), " "); got != "c-know/reading 30 a day [#####-----] 50% 15 15 30/day 20/day 20 behind" {
+		t.Errorf("Unexpected line. %s", got)
+	}
+	if got := strings.Join(strings.Fields(lines[2]), " "); got != "c-know/commits 200 by 20261231 [##########] 100% 200 0 0/day 4/day 212 achieved (3 days left)" {
+		t.Errorf("Unexpected line. %s", got)
+	}
+	if strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("output should not be colored. %q", out.String())
+	}
+
+	out.Reset()
+	printGoalStatuses(out, statuses, true)
+	if !strings.Contains(out.String(), "\x1b[33mbehind\x1b[0m") {
+		t.Errorf("output should be colored. %q", out.String())
+	}
+}
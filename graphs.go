@@ -1,34 +1,46 @@
 package pi
 
 import (
+	"bytes"
 	"fmt"
+	"image/png"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 type graphsCommand struct {
-	Create createGraphCommand    `description:"create Graph" command:"create" subcommands-optional:"true"`
-	Get    getGraphsCommand      `description:"get Graph Definitions" command:"get" subcommands-optional:"true"`
-	SVG    graphSVGCommand       `description:"get SVG Graph URL" command:"svg" subcommands-optional:"true"`
-	Update updateGraphCommand    `description:"update Graph Definition" command:"update" subcommands-optional:"true"`
-	Detail graphDetailCommand    `description:"get Graph detail URL" command:"detail" subcommands-optional:"true"`
-	List   graphListCommand      `description:"get Graph List page URL" command:"list" subcommands-optional:"true"`
-	Delete deleteGraphCommand    `description:"delete Graph" command:"delete" subcommands-optional:"true"`
-	Pixels getGraphPixelsCommand `description:"get Graph Pixels" command:"pixels" subcommands-optional:"true"`
-	Stats  getGraphStatsCommand  `description:"get Graph stats" command:"stats" subcommands-optional:"true"`
+	Create  createGraphCommand    `description:"create Graph" command:"create" subcommands-optional:"true"`
+	Get     getGraphsCommand      `description:"get Graph Definitions" command:"get" subcommands-optional:"true"`
+	SVG     graphSVGCommand       `description:"get SVG Graph URL" command:"svg" subcommands-optional:"true"`
+	Update  updateGraphCommand    `description:"update Graph Definition" command:"update" subcommands-optional:"true"`
+	Detail  graphDetailCommand    `description:"get Graph detail URL" command:"detail" subcommands-optional:"true"`
+	List    graphListCommand      `description:"get Graph List page URL" command:"list" subcommands-optional:"true"`
+	Delete  deleteGraphCommand    `description:"delete Graph" command:"delete" subcommands-optional:"true"`
+	Pixels  getGraphPixelsCommand `description:"get Graph Pixels" command:"pixels" subcommands-optional:"true"`
+	Stats   getGraphStatsCommand  `description:"get Graph stats" command:"stats" subcommands-optional:"true"`
+	Analyze analyzeGraphCommand   `description:"analyze Graph Pixels locally" command:"analyze" subcommands-optional:"true"`
+	Def     getGraphDefCommand    `description:"get a Graph Definition" command:"def" subcommands-optional:"true"`
+	Diff    diffGraphsCommand     `description:"compare Pixels of two Graphs" command:"diff" subcommands-optional:"true"`
+	Sync    syncGraphCommand      `description:"make Graph Pixels match a CSV file" command:"sync" subcommands-optional:"true"`
+	Rollup  rollupGraphCommand    `description:"aggregate Graph Pixels into a weekly or monthly Graph" command:"rollup" subcommands-optional:"true"`
+	Compute computeGraphCommand   `description:"register the results of an expression over other Graphs" command:"compute" subcommands-optional:"true"`
 }
 
 type createGraphCommand struct {
 	Username            string `short:"u" long:"username" description:"User name of graph owner."`
 	ID                  string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
-	Name                string `short:"n" long:"name" description:"The name of the pixelation graph." required:"true"`
-	Unit                string `short:"i" long:"unit" description:"A unit of the quantity recorded in the pixelation graph. Ex) commit, kilogram, calory." required:"true"`
-	Type                string `short:"t" long:"type" description:"The type of quantity to be handled in the graph. Only int or float are supported." choice:"int" choice:"float" required:"true"`
-	Color               string `short:"c" long:"color" description:"The display color of the pixel in the pixelation graph." choice:"shibafu" choice:"momiji" choice:"sora" choice:"ichou" choice:"ajisai" choice:"kuro" required:"true"`
+	Name                string `short:"n" long:"name" description:"The name of the pixelation graph."`
+	Unit                string `short:"i" long:"unit" description:"A unit of the quantity recorded in the pixelation graph. Ex) commit, kilogram, calory."`
+	Type                string `short:"t" long:"type" description:"The type of quantity to be handled in the graph. Only int or float are supported." choice:"int" choice:"float"`
+	Color               string `short:"c" long:"color" description:"The display color of the pixel in the pixelation graph." choice:"shibafu" choice:"momiji" choice:"sora" choice:"ichou" choice:"ajisai" choice:"kuro"`
 	Timezone            string `short:"z" long:"timezone" description:"The timezone for handling this graph"`
 	SelfSufficient      string `short:"s" long:"self-sufficient" description:"If SVG graph with this field 'increment' or 'decrement' is referenced, Pixel of this graph itself will be incremented or decremented." choice:"increment" choice:"decrement" choice:"none"`
 	Secret              *bool  `short:"x" long:"secret" description:"When this property is specified, the graph is hidden on the list page. This is a limited feature. For detail, see https://github.com/a-know/Pixela/wiki/How-to-support-Pixela-by-Patreon-%EF%BC%8F-Use-Limited-Features"`
 	PublishOptionalData *bool  `long:"publish-optional-data" description:"When this property is specified, the graph's each pixel optionalData will be added to the generated SVG. This is a limited feature. For detail, see https://github.com/a-know/Pixela/wiki/How-to-support-Pixela-by-Patreon-%EF%BC%8F-Use-Limited-Features"`
+	Template            string `long:"template" description:"The name of the template which fills the fields not specified. Run 'pi templates list' to see the templates."`
 }
 
 type createGraphParam struct {
@@ -48,16 +60,19 @@ type getGraphsCommand struct {
 }
 
 type graphSVGCommand struct {
-	Username   string `short:"u" long:"username" description:"User name of graph owner."`
-	ID         string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
-	Date       string `short:"d" long:"date" description:"If you specify it in yyyyMMdd format, will create a pixelation graph dating back to the past with that day as the start date."`
-	Mode       string `short:"m" long:"mode" description:"Specify the graph display mode."`
-	Appearance string `short:"a" long:"appearance" description:"Specify the graph appearance mode."`
+	Username   string  `short:"u" long:"username" description:"User name of graph owner."`
+	ID         string  `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	Date       string  `short:"d" long:"date" description:"If you specify it in yyyyMMdd format, will create a pixelation graph dating back to the past with that day as the start date."`
+	Mode       string  `short:"m" long:"mode" description:"Specify the graph display mode."`
+	Appearance string  `short:"a" long:"appearance" description:"Specify the graph appearance mode."`
+	Download   string  `long:"download" description:"Download the graph to the specified file instead of printing the URL."`
+	Format     string  `long:"format" description:"The format of the downloaded file. If omitted, it is guessed from the file extension." choice:"svg" choice:"png"`
+	Scale      float64 `long:"scale" description:"The scale factor applied when converting to PNG, up to 10. Texts are not rendered in PNG, and the line mode can not be converted." default:"1"`
 }
 
 type updateGraphCommand struct {
 	Username            string   `short:"u" long:"username" description:"User name of graph owner."`
-	ID                  string   `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	ID                  string   `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph. Multiple graphs can be specified by comma separated IDs, a glob (team-*) or a regular expression (/^team-/)." required:"true"`
 	Name                string   `short:"n" long:"name" description:"The name of the pixelation graph."`
 	Unit                string   `short:"i" long:"unit" description:"A unit of the quantity recorded in the pixelation graph. Ex) commit, kilogram, calory."`
 	Color               string   `short:"c" long:"color" description:"The display color of the pixel in the pixelation graph." choice:"shibafu" choice:"momiji" choice:"sora" choice:"ichou" choice:"ajisai" choice:"kuro"`
@@ -97,12 +112,18 @@ type deleteGraphCommand struct {
 
 type getGraphPixelsCommand struct {
 	Username string `short:"u" long:"username" description:"User name of graph owner."`
-	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph. Multiple graphs can be specified by comma separated IDs, a glob (team-*) or a regular expression (/^team-/)." required:"true"`
 	From     string `short:"f" long:"from" description:"Specify the start position of the period."`
 	To       string `short:"t" long:"to" description:"Specify the end position of the period."`
+	WithBody bool   `short:"b" long:"with-body" description:"Get the quantity and optionalData of each Pixel as well as the date."`
 }
 
 type getGraphStatsCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph. Multiple graphs can be specified by comma separated IDs, a glob (team-*) or a regular expression (/^team-/)." required:"true"`
+}
+
+type getGraphDefCommand struct {
 	Username string `short:"u" long:"username" description:"User name of graph owner."`
 	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
 }
@@ -123,6 +144,20 @@ func generateCreateGraphRequest(cG *createGraphCommand) (*http.Request, error) {
 		return nil, err
 	}
 
+	if cG.Template != "" {
+		template, err := findGraphTemplate(cG.Template)
+		if err != nil {
+			return nil, err
+		}
+		filled := *cG
+		template.apply(&filled)
+		cG = &filled
+	}
+	err = validateCreateGraphCommand(cG)
+	if err != nil {
+		return nil, err
+	}
+
 	paramStruct := &createGraphParam{
 		ID:                  cG.ID,
 		Name:                cG.Name,
@@ -147,6 +182,34 @@ func generateCreateGraphRequest(cG *createGraphCommand) (*http.Request, error) {
 	return req, nil
 }
 
+// validateCreateGraphCommand checks the fields which are required after the template is applied.
+func validateCreateGraphCommand(cG *createGraphCommand) error {
+	required := []struct {
+		flag  string
+		value string
+	}{
+		{"name", cG.Name},
+		{"unit", cG.Unit},
+		{"type", cG.Type},
+		{"color", cG.Color},
+	}
+	for _, r := range required {
+		if r.value == "" {
+			return fmt.Errorf("the required flag `--%s' was not specified", r.flag)
+		}
+	}
+	if cG.Type != "int" && cG.Type != "float" {
+		return fmt.Errorf("type must be int or float")
+	}
+	if !containsString(graphColors, cG.Color) {
+		return fmt.Errorf("color must be one of %s", strings.Join(graphColors, ", "))
+	}
+	if cG.SelfSufficient != "" && !containsString([]string{"increment", "decrement", "none"}, cG.SelfSufficient) {
+		return fmt.Errorf("self-sufficient must be one of increment, decrement and none")
+	}
+	return nil
+}
+
 func (gG *getGraphsCommand) Execute(args []string) error {
 	req, err := generateGetGraphsRequest(gG)
 	if err != nil {
@@ -181,10 +244,68 @@ func (gS *graphSVGCommand) Execute(args []string) error {
 		return err
 	}
 
-	fmt.Print(url)
+	if gS.Download == "" {
+		fmt.Print(url)
+		return nil
+	}
+	if svgDownloadFormat(gS) == "png" {
+		if err := validateRasterizeScale(gS.Scale); err != nil {
+			return err
+		}
+		if err := validateRasterizeMode(gS.Mode); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to generate svg request : %s", err)
+	}
+	svg, err := doRequestAndGetBody(req)
+	if err != nil {
+		return err
+	}
+
+	b, err := convertSVG(svg, svgDownloadFormat(gS), gS.Scale)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(gS.Download, b, 0644)
+	if err != nil {
+		return fmt.Errorf("Failed to write file : %s", err)
+	}
 	return nil
 }
 
+func svgDownloadFormat(gS *graphSVGCommand) string {
+	if gS.Format != "" {
+		return gS.Format
+	}
+	if strings.EqualFold(filepath.Ext(gS.Download), ".png") {
+		return "png"
+	}
+	return "svg"
+}
+
+func convertSVG(svg []byte, format string, scale float64) ([]byte, error) {
+	if format != "png" {
+		return svg, nil
+	}
+
+	img, err := rasterizeSVG(svg, scale)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := &bytes.Buffer{}
+	err = png.Encode(buffer, img)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode png : %s", err)
+	}
+	return buffer.Bytes(), nil
+}
+
 func generateSVGUrl(gS *graphSVGCommand) (string, error) {
 	username, err := getUsername(gS.Username)
 	if err != nil {
@@ -217,6 +338,14 @@ func generateSVGUrl(gS *graphSVGCommand) (string, error) {
 }
 
 func (uG *updateGraphCommand) Execute(args []string) error {
+	if isMultiGraph(uG.ID) {
+		return doMultiGraphRequests(uG.Username, uG.ID, func(id string) (*http.Request, error) {
+			cmd := *uG
+			cmd.ID = id
+			return generateUpdateGraphRequest(&cmd)
+		})
+	}
+
 	req, err := generateUpdateGraphRequest(uG)
 	if err != nil {
 		return err
@@ -348,6 +477,14 @@ func generateDeleteGraphRequest(dG *deleteGraphCommand) (*http.Request, error) {
 }
 
 func (gGP *getGraphPixelsCommand) Execute(args []string) error {
+	if isMultiGraph(gGP.ID) {
+		return doMultiGraphRequests(gGP.Username, gGP.ID, func(id string) (*http.Request, error) {
+			cmd := *gGP
+			cmd.ID = id
+			return generateGetGraphPixelsRequest(&cmd)
+		})
+	}
+
 	req, err := generateGetGraphPixelsRequest(gGP)
 	if err != nil {
 		return err
@@ -365,13 +502,18 @@ func generateGetGraphPixelsRequest(gGP *getGraphPixelsCommand) (*http.Request, e
 
 	url := fmt.Sprintf("v1/users/%s/graphs/%s/pixels", username, gGP.ID)
 
+	params := []string{}
 	if gGP.From != "" {
-		url = fmt.Sprintf("%s?from=%s", url, gGP.From)
-		if gGP.To != "" {
-			url = fmt.Sprintf("%s&to=%s", url, gGP.To)
-		}
-	} else if gGP.To != "" {
-		url = fmt.Sprintf("%s?to=%s", url, gGP.To)
+		params = append(params, fmt.Sprintf("from=%s", gGP.From))
+	}
+	if gGP.To != "" {
+		params = append(params, fmt.Sprintf("to=%s", gGP.To))
+	}
+	if gGP.WithBody {
+		params = append(params, "withBody=true")
+	}
+	if len(params) > 0 {
+		url = fmt.Sprintf("%s?%s", url, strings.Join(params, "&"))
 	}
 
 	req, err := generateRequestWithToken(
@@ -387,6 +529,14 @@ func generateGetGraphPixelsRequest(gGP *getGraphPixelsCommand) (*http.Request, e
 }
 
 func (gS *getGraphStatsCommand) Execute(args []string) error {
+	if isMultiGraph(gS.ID) {
+		return doMultiGraphRequests(gS.Username, gS.ID, func(id string) (*http.Request, error) {
+			cmd := *gS
+			cmd.ID = id
+			return generateGetGraphStatsRequest(&cmd)
+		})
+	}
+
 	req, err := generateGetGraphStatsRequest(gS)
 	if err != nil {
 		return err
@@ -412,3 +562,30 @@ func generateGetGraphStatsRequest(gS *getGraphStatsCommand) (*http.Request, erro
 	}
 	return req, nil
 }
+
+func (gD *getGraphDefCommand) Execute(args []string) error {
+	req, err := generateGetGraphDefRequest(gD)
+	if err != nil {
+		return err
+	}
+
+	err = doRequest(req)
+	return err
+}
+
+func generateGetGraphDefRequest(gD *getGraphDefCommand) (*http.Request, error) {
+	username, err := getUsername(gD.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := generateRequestWithToken(
+		"GET",
+		fmt.Sprintf("v1/users/%s/graphs/%s/graph-def", username, gD.ID),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate get api request : %s", err)
+	}
+	return req, nil
+}
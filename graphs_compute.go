@@ -0,0 +1,377 @@
+package pi
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type computeGraphCommand struct {
+	Username  string   `short:"u" long:"username" description:"User name of graph owner."`
+	Target    string   `long:"target" description:"ID of the graph to register the results." required:"true"`
+	Expr      string   `long:"expr" description:"Arithmetic expression over the inputs with + - * / and parentheses. Ex) 'a + b + c', 'minutes * 0.8'" required:"true"`
+	Inputs    []string `long:"input" description:"Variable of the expression as <name>=[<username>/]<graph-id>. Multiple params can be specified." required:"true"`
+	Missing   string   `long:"missing" description:"How to treat an input without pixel on a date. zero uses 0, skip does not compute the date." choice:"zero" choice:"skip" default:"zero"`
+	From      string   `short:"f" long:"from" description:"Specify the start position of the period in yyyyMMdd format. Defaults to a year ago."`
+	To        string   `short:"t" long:"to" description:"Specify the end position of the period in yyyyMMdd format. Defaults to today."`
+	DryRun    bool     `long:"dry-run" description:"Show the results without updating the graph."`
+	ChunkSize int      `long:"chunk-size" description:"The number of Pixels posted in one request." default:"100"`
+}
+
+// exprNode is a node of the parsed expression.
+type exprNode interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type numberNode float64
+
+type variableNode string
+
+type unaryNode struct {
+	operand exprNode
+}
+
+type binaryNode struct {
+	op          byte
+	left, right exprNode
+}
+
+func (n numberNode) eval(vars map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (n variableNode) eval(vars map[string]float64) (float64, error) {
+	v, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("undefined variable `%s`", string(n))
+	}
+	return v, nil
+}
+
+func (n *unaryNode) eval(vars map[string]float64) (float64, error) {
+	v, err := n.operand.eval(vars)
+	return -v, err
+}
+
+func (n *binaryNode) eval(vars map[string]float64) (float64, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	}
+	if r == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return l / r, nil
+}
+
+func (cG *computeGraphCommand) Execute(args []string) error {
+	username, err := getUsername(cG.Username)
+	if err != nil {
+		return err
+	}
+	inputs, err := parseComputeInputs(cG.Inputs, username)
+	if err != nil {
+		return err
+	}
+	expr, err := parseExpression(cG.Expr)
+	if err != nil {
+		return err
+	}
+	for _, name := range exprVariables(expr) {
+		if _, ok := inputs[name]; !ok {
+			return fmt.Errorf("variable `%s` is not given by --input", name)
+		}
+	}
+
+	def, err := fetchGraphDefinition(username, cG.Target)
+	if err != nil {
+		return err
+	}
+	today, err := def.today()
+	if err != nil {
+		return err
+	}
+	from, to, err := pixelPeriod(today, cG.From, cG.To)
+	if err != nil {
+		return err
+	}
+
+	// the period may be longer than a year, and truncated inputs would overwrite the registered results.
+	values := map[string]map[string]float64{}
+	for name, ref := range inputs {
+		pixels, err := fetchGraphPixelsInWindows(ref.Username, ref.ID, from, to)
+		if err != nil {
+			return err
+		}
+		values[name], err = pixelQuantities(pixels)
+		if err != nil {
+			return err
+		}
+	}
+
+	results, failures := computeQuantities(expr, values, cG.Missing == "skip")
+	for _, f := range failures {
+		log.Printf("warning: %s is skipped : %s", f.Date, f.Err)
+	}
+	for i, r := range results {
+		results[i].Quantity, _ = strconv.ParseFloat(def.formatQuantity(r.Quantity), 64)
+	}
+	registered, err := fetchGraphPixelsInWindows(username, cG.Target, from, to)
+	if err != nil {
+		return err
+	}
+	results, err = changedQuantities(results, registered)
+	if err != nil {
+		return err
+	}
+
+	pixels := []postPixelParam{}
+	for _, r := range results {
+		quantity := def.formatQuantity(r.Quantity)
+		fmt.Printf("%s\t%s\n", r.Date, quantity)
+		pixels = append(pixels, postPixelParam{Date: r.Date, Quantity: quantity})
+	}
+	if cG.DryRun || len(pixels) == 0 {
+		return nil
+	}
+	return postPixelsInBatches(&batchPixelsCommand{Username: username, ID: cG.Target, ChunkSize: cG.ChunkSize}, pixels)
+}
+
+// parseComputeInputs parses `<name>=[<username>/]<graph-id>` into the graphs keyed by the variable name.
+func parseComputeInputs(inputs []string, username string) (map[string]graphRef, error) {
+	refs := map[string]graphRef{}
+	for _, input := range inputs {
+		i := strings.Index(input, "=")
+		if i < 1 || i == len(input)-1 {
+			return nil, fmt.Errorf("input must be specified as <name>=[<username>/]<graph-id> : %s", input)
+		}
+		name := input[:i]
+		if !isExprIdentifier(name) {
+			return nil, fmt.Errorf("invalid variable name `%s`", name)
+		}
+		if _, ok := refs[name]; ok {
+			return nil, fmt.Errorf("variable `%s` is specified twice", name)
+		}
+		refs[name] = parseGraphRef(input[i+1:], username)
+	}
+	return refs, nil
+}
+
+// computeFailure is a date on which the expression can not be evaluated, such as division by zero.
+type computeFailure struct {
+	Date string
+	Err  error
+}
+
+// computeQuantities evaluates the expression on each date which any input has a pixel.
+// With `skip`, the dates which some input does not have are not computed, otherwise they are treated as zero.
+// The dates which can not be evaluated are returned as failures, so that they do not stop the other dates.
+func computeQuantities(expr exprNode, values map[string]map[string]float64, skip bool) ([]dailyQuantity, []computeFailure) {
+	dates := map[string]bool{}
+	for _, quantities := range values {
+		for date := range quantities {
+			dates[date] = true
+		}
+	}
+	sorted := []string{}
+	for date := range dates {
+		sorted = append(sorted, date)
+	}
+	sort.Strings(sorted)
+
+	results := []dailyQuantity{}
+	failures := []computeFailure{}
+	for _, date := range sorted {
+		vars := map[string]float64{}
+		complete := true
+		for name, quantities := range values {
+			q, ok := quantities[date]
+			if !ok {
+				complete = false
+			}
+			vars[name] = q
+		}
+		if skip && !complete {
+			continue
+		}
+		v, err := expr.eval(vars)
+		if err == nil && (math.IsInf(v, 0) || math.IsNaN(v)) {
+			err = fmt.Errorf("the result is not a number")
+		}
+		if err != nil {
+			failures = append(failures, computeFailure{Date: date, Err: err})
+			continue
+		}
+		results = append(results, dailyQuantity{Date: date, Quantity: v})
+	}
+	return results, failures
+}
+
+func exprVariables(node exprNode) []string {
+	switch n := node.(type) {
+	case variableNode:
+		return []string{string(n)}
+	case *unaryNode:
+		return exprVariables(n.operand)
+	case *binaryNode:
+		return append(exprVariables(n.left), exprVariables(n.right)...)
+	}
+	return nil
+}
+
+// exprParser is a recursive descent parser of
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = number | identifier | "(" expr ")" | "-" factor
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseExpression(s string) (exprNode, error) {
+	tokens, err := tokenizeExpression(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	node, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid expression `%s` : unexpected `%s`", s, p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+func tokenizeExpression(s string) ([]string, error) {
+	tokens := []string{}
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("+-*/()", r):
+			tokens = append(tokens, string(r))
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		case r == '_' || unicode.IsLetter(r):
+			j := i
+			for j < len(runes) && (runes[j] == '_' || unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		default:
+			return nil, fmt.Errorf("invalid expression `%s` : unexpected `%c`", s, r)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	return tokens, nil
+}
+
+func isExprIdentifier(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) expr() (exprNode, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "+" || op == "-"; op = p.peek() {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op[0], left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) term() (exprNode, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "*" || op == "/"; op = p.peek() {
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op[0], left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) factor() (exprNode, error) {
+	token := p.peek()
+	p.pos++
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("invalid expression : unexpected end")
+	case token == "-":
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{operand: operand}, nil
+	case token == "(":
+		node, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("invalid expression : `)` is missing")
+		}
+		p.pos++
+		return node, nil
+	case isExprIdentifier(token):
+		return variableNode(token), nil
+	}
+	v, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression : unexpected `%s`", token)
+	}
+	return numberNode(v), nil
+}
@@ -0,0 +1,150 @@
+package pi
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeGraphCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		exitCode int
+	}{
+		{"compute graph - not specify target", []string{"graphs", "compute", "--username", "c-know", "--expr", "a", "--input", "a=graph-a"}, 1},
+		{"compute graph - not specify expr", []string{"graphs", "compute", "--username", "c-know", "--target", "total", "--input", "a=graph-a"}, 1},
+		{"compute graph - not specify input", []string{"graphs", "compute", "--username", "c-know", "--target", "total", "--expr", "a"}, 1},
+		{"compute graph - invalid input", []string{"graphs", "compute", "--username", "c-know", "--target", "total", "--expr", "a", "--input", "graph-a"}, 1},
+		{"compute graph - invalid expr", []string{"graphs", "compute", "--username", "c-know", "--target", "total", "--expr", "a +", "--input", "a=graph-a"}, 1},
+		{"compute graph - undefined variable", []string{"graphs", "compute", "--username", "c-know", "--target", "total", "--expr", "a + b", "--input", "a=graph-a"}, 1},
+		{"compute graph - invalid missing", []string{"graphs", "compute", "--username", "c-know", "--target", "total", "--expr", "a", "--input", "a=graph-a", "--missing", "ignore"}, 1},
+	}
+	for _, tt := range tests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	vars := map[string]float64{"a": 2, "b": 3, "reading_minutes": 10}
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"a + b * 2", 8},
+		{"(a + b) * 2", 10},
+		{"a - b - 1", -2},
+		{"a / b * 3", 2},
+		{"-a + -(b)", -5},
+		{"reading_minutes * 0.8", 8},
+		{"  1.5  ", 1.5},
+	}
+	for _, tt := range tests {
+		expr, err := parseExpression(tt.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error. %s", tt.expr, err)
+			continue
+		}
+		got, err := expr.eval(vars)
+		if err != nil || roundNumber(got) != tt.want {
+			t.Errorf("%s: out=%v want=%v err=%v", tt.expr, got, tt.want, err)
+		}
+	}
+
+	invalids := []string{"", "a +", "(a + b", "a b", "a % b", "1.2.3", ")"}
+	for _, expr := range invalids {
+		if _, err := parseExpression(expr); err == nil {
+			t.Errorf("%s: expected error but not occurred", expr)
+		}
+	}
+
+	expr, _ := parseExpression("a / (b - 3)")
+	if _, err := expr.eval(vars); err == nil {
+		t.Errorf("expected division by zero")
+	}
+	expr, _ = parseExpression("a + b * (a - c)")
+	if got := exprVariables(expr); !reflect.DeepEqual(got, []string{"a", "b", "a", "c"}) {
+		t.Errorf("Unexpected variables. %v", got)
+	}
+}
+
+func TestParseComputeInputs(t *testing.T) {
+	refs, err := parseComputeInputs([]string{"a=alice/commits", "b=commits"}, "c-know")
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if refs["a"] != (graphRef{Username: "alice", ID: "commits"}) || refs["b"] != (graphRef{Username: "c-know", ID: "commits"}) {
+		t.Errorf("Unexpected inputs. %v", refs)
+	}
+
+	invalids := [][]string{{"=commits"}, {"a="}, {"1a=commits"}, {"a=commits", "a=other"}}
+	for _, inputs := range invalids {
+		if _, err := parseComputeInputs(inputs, "c-know"); err == nil {
+			t.Errorf("%v: expected error but not occurred", inputs)
+		}
+	}
+}
+
+func TestComputeQuantities(t *testing.T) {
+	expr, _ := parseExpression("a + b * 0.5")
+	values := map[string]map[string]float64{
+		"a": {"20190101": 1, "20190102": 2},
+		"b": {"20190102": 4, "20190103": 6},
+	}
+
+	got, failures := computeQuantities(expr, values, false)
+	if len(failures) != 0 {
+		t.Fatalf("Unexpected failures. %v", failures)
+	}
+	want := []dailyQuantity{{"20190101", 1}, {"20190102", 4}, {"20190103", 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("out=%v want=%v", got, want)
+	}
+
+	got, failures = computeQuantities(expr, values, true)
+	if len(failures) != 0 || !reflect.DeepEqual(got, []dailyQuantity{{"20190102", 4}}) {
+		t.Errorf("Unexpected results with skip. %v %v", got, failures)
+	}
+
+	// division by zero on a date does not stop the other dates.
+	expr, _ = parseExpression("a / b")
+	got, failures = computeQuantities(expr, values, false)
+	if !reflect.DeepEqual(got, []dailyQuantity{{"20190102", 0.5}, {"20190103", 0}}) {
+		t.Errorf("Unexpected results with division by zero. %v", got)
+	}
+	if len(failures) != 1 || failures[0].Date != "20190101" {
+		t.Errorf("Unexpected failures. %v", failures)
+	}
+}
+
+func TestPixelPeriod(t *testing.T) {
+	today, _ := time.Parse(pixelDateLayout, "20190615")
+	tests := []struct {
+		from, to         string
+		wantFrom, wantTo string
+		wantErr          bool
+	}{
+		{"", "", "20180616", "20190615", false},
+		{"20150101", "", "20150101", "20190615", false},
+		{"20150101", "20151231", "20150101", "20151231", false},
+		{"", "20180101", "", "", true},
+		{"2015-01-01", "", "", "", true},
+	}
+	for _, tt := range tests {
+		from, to, err := pixelPeriod(today, tt.from, tt.to)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s-%s: unexpected error. %v", tt.from, tt.to, err)
+			continue
+		}
+		if from != tt.wantFrom || to != tt.wantTo {
+			t.Errorf("%s-%s: out=%s-%s want=%s-%s", tt.from, tt.to, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
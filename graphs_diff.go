@@ -0,0 +1,244 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+type diffGraphsCommand struct {
+	Username  string `short:"u" long:"username" description:"User name used for the graphs specified without user name."`
+	From      string `short:"f" long:"from" description:"Specify the start position of the period in yyyyMMdd format. Defaults to a year ago."`
+	To        string `short:"t" long:"to" description:"Specify the end position of the period in yyyyMMdd format. Defaults to today in the left graph's timezone."`
+	RightFrom string `long:"right-from" description:"Compare with the period of the right graph starting from this date in yyyyMMdd format. The dates are aligned by the offset from --from."`
+	Format    string `long:"format" description:"Output format." choice:"table" choice:"json" default:"table"`
+}
+
+type graphDiff struct {
+	Left      string        `json:"left"`
+	Right     string        `json:"right"`
+	Offset    int           `json:"offset,omitempty"`
+	OnlyLeft  []pixel       `json:"onlyLeft"`
+	OnlyRight []pixel       `json:"onlyRight"`
+	Changed   []pixelChange `json:"changed"`
+	Summary   diffSummary   `json:"summary"`
+}
+
+// pixelChange is a date which both graphs have with different quantity or optionalData.
+// The date of the right graph differs only when the periods are shifted.
+type pixelChange struct {
+	Date  string `json:"date"`
+	Left  pixel  `json:"left"`
+	Right pixel  `json:"right"`
+}
+
+type diffSummary struct {
+	LeftPixels  int     `json:"leftPixels"`
+	RightPixels int     `json:"rightPixels"`
+	Same        int     `json:"same"`
+	LeftTotal   float64 `json:"leftTotal"`
+	RightTotal  float64 `json:"rightTotal"`
+	Delta       float64 `json:"delta"`
+}
+
+func (dG *diffGraphsCommand) Execute(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("specify two graphs as [<username>/]<graph-id>")
+	}
+	username, err := getUsername(dG.Username)
+	if err != nil {
+		return err
+	}
+	left := parseGraphRef(args[0], username)
+	right := parseGraphRef(args[1], username)
+
+	offset, shiftedTo, err := dG.rightPeriod()
+	if err != nil {
+		return err
+	}
+
+	// the graphs of other users are read with their token in the config file. See doUserRequest.
+	def, err := fetchGraphDefinition(left.Username, left.ID)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch %s : %s", left, err)
+	}
+	today, err := def.today()
+	if err != nil {
+		return err
+	}
+	from, to, err := pixelPeriod(today, dG.From, dG.To)
+	if err != nil {
+		return err
+	}
+	rightFrom, rightTo := from, to
+	if dG.RightFrom != "" {
+		rightFrom, rightTo = dG.RightFrom, shiftedTo
+	}
+
+	// the period may be longer than a year, and a truncated period would report the pixels out of it as differences.
+	leftPixels, err := fetchGraphPixelsInWindows(left.Username, left.ID, from, to)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch %s : %s", left, err)
+	}
+	rightPixels, err := fetchGraphPixelsInWindows(right.Username, right.ID, rightFrom, rightTo)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch %s : %s", right, err)
+	}
+
+	diff, err := diffPixels(leftPixels, rightPixels, offset)
+	if err != nil {
+		return err
+	}
+	diff.Left, diff.Right = left.String(), right.String()
+
+	if dG.Format == "json" {
+		err = json.NewEncoder(os.Stdout).Encode(diff)
+	} else {
+		printGraphDiff(os.Stdout, diff)
+	}
+	if err != nil {
+		return err
+	}
+	if n := diff.differences(); n > 0 {
+		return fmt.Errorf("%s and %s differ on %d dates", diff.Left, diff.Right, n)
+	}
+	return nil
+}
+
+// rightPeriod returns the offset in days and the end of the period of the right graph.
+func (dG *diffGraphsCommand) rightPeriod() (int, string, error) {
+	if dG.RightFrom == "" {
+		return 0, dG.To, nil
+	}
+	if dG.From == "" || dG.To == "" {
+		return 0, "", fmt.Errorf("--from and --to are required with --right-from")
+	}
+	from, err := time.Parse(pixelDateLayout, dG.From)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid date `%s`", dG.From)
+	}
+	to, err := time.Parse(pixelDateLayout, dG.To)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid date `%s`", dG.To)
+	}
+	rightFrom, err := time.Parse(pixelDateLayout, dG.RightFrom)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid date `%s`", dG.RightFrom)
+	}
+	offset := int(rightFrom.Sub(from).Hours() / 24)
+	return offset, to.AddDate(0, 0, offset).Format(pixelDateLayout), nil
+}
+
+// diffPixels compares the pixels by date. The dates of the right pixels are shifted back by `offset` days.
+func diffPixels(left []pixel, right []pixel, offset int) (*graphDiff, error) {
+	diff := &graphDiff{Offset: offset, OnlyLeft: []pixel{}, OnlyRight: []pixel{}, Changed: []pixelChange{}}
+
+	rights := map[string]pixel{}
+	for _, p := range right {
+		date, err := shiftPixelDate(p.Date, -offset)
+		if err != nil {
+			return nil, err
+		}
+		rights[date] = p
+		q, err := strconv.ParseFloat(p.Quantity, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity `%s` on %s", p.Quantity, p.Date)
+		}
+		diff.Summary.RightTotal += q
+	}
+	diff.Summary.RightPixels = len(right)
+
+	for _, l := range left {
+		lq, err := strconv.ParseFloat(l.Quantity, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity `%s` on %s", l.Quantity, l.Date)
+		}
+		diff.Summary.LeftTotal += lq
+
+		r, ok := rights[l.Date]
+		if !ok {
+			diff.OnlyLeft = append(diff.OnlyLeft, l)
+			continue
+		}
+		delete(rights, l.Date)
+		rq, _ := strconv.ParseFloat(r.Quantity, 64)
+		if lq != rq || l.OptionalData != r.OptionalData {
+			diff.Changed = append(diff.Changed, pixelChange{Date: l.Date, Left: l, Right: r})
+			continue
+		}
+		diff.Summary.Same++
+	}
+	diff.Summary.LeftPixels = len(left)
+
+	for _, r := range rights {
+		diff.OnlyRight = append(diff.OnlyRight, r)
+	}
+	sort.Slice(diff.OnlyRight, func(i, j int) bool {
+		return diff.OnlyRight[i].Date < diff.OnlyRight[j].Date
+	})
+	diff.Summary.LeftTotal = roundNumber(diff.Summary.LeftTotal)
+	diff.Summary.RightTotal = roundNumber(diff.Summary.RightTotal)
+	diff.Summary.Delta = roundNumber(diff.Summary.RightTotal - diff.Summary.LeftTotal)
+	return diff, nil
+}
+
+func shiftPixelDate(date string, days int) (string, error) {
+	if days == 0 {
+		return date, nil
+	}
+	t, err := time.Parse(pixelDateLayout, date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date `%s`", date)
+	}
+	return t.AddDate(0, 0, days).Format(pixelDateLayout), nil
+}
+
+func (d *graphDiff) differences() int {
+	return len(d.OnlyLeft) + len(d.OnlyRight) + len(d.Changed)
+}
+
+// printGraphDiff prints the differences in order of the date of the left graph.
+// The pixels only in the right graph are shown at the dates shifted back by the offset.
+func printGraphDiff(out io.Writer, d *graphDiff) {
+	type row struct {
+		date, left, right, delta, note string
+	}
+	rows := []row{}
+	for _, p := range d.OnlyLeft {
+		rows = append(rows, row{p.Date, p.Quantity, "-", "-", "only in left"})
+	}
+	for _, p := range d.OnlyRight {
+		date, _ := shiftPixelDate(p.Date, -d.Offset)
+		rows = append(rows, row{date, "-", p.Quantity, "-", "only in right"})
+	}
+	for _, c := range d.Changed {
+		lq, _ := strconv.ParseFloat(c.Left.Quantity, 64)
+		rq, _ := strconv.ParseFloat(c.Right.Quantity, 64)
+		note := "quantity"
+		if lq == rq {
+			note = "optionalData"
+		} else if c.Left.OptionalData != c.Right.OptionalData {
+			note = "quantity, optionalData"
+		}
+		rows = append(rows, row{c.Date, c.Left.Quantity, c.Right.Quantity, formatNumber(rq - lq), note})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].date < rows[j].date })
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "date\tleft\tright\tdelta\tdifference")
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.date, r.left, r.right, r.delta, r.note)
+	}
+	w.Flush()
+
+	s := d.Summary
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "left   %s: %d pixels, total %s\n", d.Left, s.LeftPixels, formatNumber(s.LeftTotal))
+	fmt.Fprintf(out, "right  %s: %d pixels, total %s\n", d.Right, s.RightPixels, formatNumber(s.RightTotal))
+	fmt.Fprintf(out, "%d same, %d only in left, %d only in right, %d changed, total delta %s\n", s.Same, len(d.OnlyLeft), len(d.OnlyRight), len(d.Changed), formatNumber(s.Delta))
+}
@@ -0,0 +1,153 @@
+package pi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDiffGraphsCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		exitCode int
+	}{
+		{"diff graphs - not specify graphs", []string{"graphs", "diff", "--username", "c-know"}, 1},
+		{"diff graphs - one graph", []string{"graphs", "diff", "--username", "c-know", "graph-a"}, 1},
+		{"diff graphs - right-from without period", []string{"graphs", "diff", "--username", "c-know", "graph-a", "graph-b", "--right-from", "20200101"}, 1},
+		{"diff graphs - invalid format", []string{"graphs", "diff", "--username", "c-know", "graph-a", "graph-b", "--format", "csv"}, 1},
+	}
+	for _, tt := range tests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestDiffGraphsCommandOverYears(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.addGraph("c-know", graphDefinition{ID: "graph-a", Type: "int"})
+	pixela.addGraph("c-know", graphDefinition{ID: "graph-b", Type: "int"})
+	for _, date := range []string{"20180105", "20191230"} {
+		pixela.setPixel("c-know/graph-a", date, pixelBody{Quantity: "1"})
+		pixela.setPixel("c-know/graph-b", date, pixelBody{Quantity: "1"})
+	}
+
+	run := func() int {
+		return (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run([]string{"graphs", "diff", "graph-a", "graph-b", "--from", "20180101", "--to", "20191231"})
+	}
+	if exitCode := run(); exitCode != 0 {
+		t.Errorf("same graphs: exitCode=%d want=0", exitCode)
+	}
+	fetched := 0
+	for _, r := range pixela.requested() {
+		if strings.HasSuffix(r, "/pixels") {
+			fetched++
+		}
+	}
+	if fetched != 4 {
+		t.Errorf("the period over a year should be fetched by year. %v", pixela.requested())
+	}
+
+	// a difference in the first year is not lost by the default period of the API.
+	pixela.setPixel("c-know/graph-b", "20180105", pixelBody{Quantity: "2"})
+	if exitCode := run(); exitCode != 1 {
+		t.Errorf("different graphs: exitCode=%d want=1", exitCode)
+	}
+}
+
+func TestDiffPixels(t *testing.T) {
+	left := []pixel{
+		{Date: "20190101", Quantity: "1"},
+		{Date: "20190102", Quantity: "2"},
+		{Date: "20190103", Quantity: "3", OptionalData: `{"a":1}`},
+		{Date: "20190104", Quantity: "4"},
+	}
+	right := []pixel{
+		{Date: "20190102", Quantity: "2.0"},
+		{Date: "20190103", Quantity: "3", OptionalData: `{"a":2}`},
+		{Date: "20190104", Quantity: "6"},
+		{Date: "20190105", Quantity: "0.1"},
+	}
+
+	diff, err := diffPixels(left, right, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if len(diff.OnlyLeft) != 1 || diff.OnlyLeft[0].Date != "20190101" {
+		t.Errorf("Unexpected onlyLeft. %v", diff.OnlyLeft)
+	}
+	if len(diff.OnlyRight) != 1 || diff.OnlyRight[0].Date != "20190105" {
+		t.Errorf("Unexpected onlyRight. %v", diff.OnlyRight)
+	}
+	if len(diff.Changed) != 2 || diff.Changed[0].Date != "20190103" || diff.Changed[1].Date != "20190104" {
+		t.Errorf("Unexpected changed. %v", diff.Changed)
+	}
+	want := diffSummary{LeftPixels: 4, RightPixels: 4, Same: 1, LeftTotal: 10, RightTotal: 11.1, Delta: 1.1}
+	if diff.Summary != want {
+		t.Errorf("Unexpected summary. %+v", diff.Summary)
+	}
+	if diff.differences() != 4 {
+		t.Errorf("Unexpected differences. %d", diff.differences())
+	}
+
+	same, err := diffPixels(left, left, 0)
+	if err != nil || same.differences() != 0 {
+		t.Errorf("the same pixels should not differ. %v %v", same, err)
+	}
+
+	_, err = diffPixels(left, []pixel{{Date: "20190101", Quantity: "many"}}, 0)
+	if err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
+
+func TestDiffPixelsWithOffset(t *testing.T) {
+	left := []pixel{{Date: "20190101", Quantity: "1"}, {Date: "20190102", Quantity: "2"}}
+	right := []pixel{{Date: "20200101", Quantity: "1"}, {Date: "20200103", Quantity: "3"}}
+
+	diff, err := diffPixels(left, right, 365)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if diff.Summary.Same != 1 || len(diff.OnlyLeft) != 1 || len(diff.OnlyRight) != 1 {
+		t.Errorf("Unexpected diff. %+v", diff)
+	}
+
+	out := &bytes.Buffer{}
+	diff.Left, diff.Right = "c-know/graph-a", "c-know/graph-a"
+	printGraphDiff(out, diff)
+	lines := strings.Split(out.String(), "\n")
+	if got := strings.Join(strings.Fields(lines[2]), " "); got != "20190103 - 3 - only in right" {
+		t.Errorf("Unexpected line. %s", got)
+	}
+	if !strings.Contains(out.String(), "1 same, 1 only in left, 1 only in right, 0 changed, total delta 1") {
+		t.Errorf("Unexpected summary. %s", out.String())
+	}
+}
+
+func TestDiffGraphsRightPeriod(t *testing.T) {
+	cmd := &diffGraphsCommand{From: "20190101", To: "20190131", RightFrom: "20200101"}
+	offset, to, err := cmd.rightPeriod()
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if offset != 365 || to != "20200131" {
+		t.Errorf("Unexpected period. %d %s", offset, to)
+	}
+
+	cmd = &diffGraphsCommand{To: "20190131"}
+	offset, to, err = cmd.rightPeriod()
+	if err != nil || offset != 0 || to != "20190131" {
+		t.Errorf("Unexpected period. %d %s %v", offset, to, err)
+	}
+}
@@ -0,0 +1,171 @@
+package pi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type rollupGraphCommand struct {
+	Username  string `short:"u" long:"username" description:"User name of graph owner."`
+	Source    string `long:"source" description:"ID of the graph to aggregate. [<username>/]<graph-id> is also accepted." required:"true"`
+	Target    string `long:"target" description:"ID of the graph to register the aggregates." required:"true"`
+	Period    string `long:"period" description:"The period to aggregate. The aggregate is registered on its first day (Monday for week)." choice:"week" choice:"month" default:"month"`
+	Func      string `long:"func" description:"The function to aggregate the pixels of a period. avg is the average of the recorded pixels." choice:"sum" choice:"avg" choice:"max" default:"sum"`
+	From      string `short:"f" long:"from" description:"Specify the start position of the period in yyyyMMdd format. It is moved back to the first day of the period. The first period start within a year by default."`
+	To        string `short:"t" long:"to" description:"Specify the end position of the period in yyyyMMdd format. Defaults to today."`
+	DryRun    bool   `long:"dry-run" description:"Show the aggregates without updating the graph."`
+	ChunkSize int    `long:"chunk-size" description:"The number of Pixels posted in one request." default:"100"`
+}
+
+func (rG *rollupGraphCommand) Execute(args []string) error {
+	username, err := getUsername(rG.Username)
+	if err != nil {
+		return err
+	}
+	source := parseGraphRef(rG.Source, username)
+
+	def, err := fetchGraphDefinition(username, rG.Target)
+	if err != nil {
+		return err
+	}
+	today, err := def.today()
+	if err != nil {
+		return err
+	}
+	from, to, err := rollupPeriod(today, rG.Period, rG.From, rG.To)
+	if err != nil {
+		return err
+	}
+
+	// the period may be longer than a year, and a truncated period would overwrite the registered aggregates.
+	pixels, err := fetchGraphPixelsInWindows(source.Username, source.ID, from, to)
+	if err != nil {
+		return err
+	}
+	rollups, err := rollupPixels(pixels, rG.Period, rG.Func)
+	if err != nil {
+		return err
+	}
+	for i, r := range rollups {
+		rollups[i].Quantity, _ = strconv.ParseFloat(def.formatQuantity(r.Quantity), 64)
+	}
+
+	// the registered aggregates are skipped so that running it again changes nothing.
+	registered, err := fetchGraphPixelsInWindows(username, rG.Target, from, to)
+	if err != nil {
+		return err
+	}
+	rollups, err = changedQuantities(rollups, registered)
+	if err != nil {
+		return err
+	}
+
+	pixelsToPost := []postPixelParam{}
+	for _, r := range rollups {
+		quantity := def.formatQuantity(r.Quantity)
+		fmt.Printf("%s\t%s\n", r.Date, quantity)
+		pixelsToPost = append(pixelsToPost, postPixelParam{Date: r.Date, Quantity: quantity})
+	}
+	if rG.DryRun || len(pixelsToPost) == 0 {
+		return nil
+	}
+	return postPixelsInBatches(&batchPixelsCommand{Username: username, ID: rG.Target, ChunkSize: rG.ChunkSize}, pixelsToPost)
+}
+
+// rollupPixels aggregates the pixels per period, and returns the aggregates dated on the first day of each period.
+func rollupPixels(pixels []pixel, period string, function string) ([]dailyQuantity, error) {
+	groups := map[string][]float64{}
+	for _, p := range pixels {
+		t, err := time.Parse(pixelDateLayout, p.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date `%s`", p.Date)
+		}
+		q, err := strconv.ParseFloat(p.Quantity, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity `%s` on %s", p.Quantity, p.Date)
+		}
+		key := rollupPeriodStart(t, period).Format(pixelDateLayout)
+		groups[key] = append(groups[key], q)
+	}
+
+	rollups := []dailyQuantity{}
+	for date, values := range groups {
+		v, err := aggregateQuantities(values, function)
+		if err != nil {
+			return nil, err
+		}
+		rollups = append(rollups, dailyQuantity{Date: date, Quantity: v})
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Date < rollups[j].Date })
+	return rollups, nil
+}
+
+func rollupPeriodStart(t time.Time, period string) time.Time {
+	if period == "week" {
+		// weeks start on Monday as ISO 8601.
+		return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// rollupPeriod returns the period to aggregate. `from` is moved back to the start of its period,
+// and the period defaults to the one from defaultRollupFrom to today.
+func rollupPeriod(today time.Time, period string, from string, to string) (string, string, error) {
+	start := defaultRollupFrom(today, period)
+	if from != "" {
+		t, err := time.Parse(pixelDateLayout, from)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid date `%s`", from)
+		}
+		start = rollupPeriodStart(t, period)
+	}
+	end := today.Format(pixelDateLayout)
+	if to != "" {
+		if _, err := time.Parse(pixelDateLayout, to); err != nil {
+			return "", "", fmt.Errorf("invalid date `%s`", to)
+		}
+		end = to
+	}
+	if end < start.Format(pixelDateLayout) {
+		return "", "", fmt.Errorf("--to must not be before %s", start.Format(pixelDateLayout))
+	}
+	return start.Format(pixelDateLayout), end, nil
+}
+
+// defaultRollupFrom returns the first period start within a year, which is the default period of the API.
+// The periods partially out of it are not aggregated, so that their registered aggregates are never overwritten.
+func defaultRollupFrom(today time.Time, period string) time.Time {
+	oldest := today.AddDate(-1, 0, 0)
+	start := rollupPeriodStart(oldest, period)
+	if start.Before(oldest) {
+		if period == "week" {
+			return start.AddDate(0, 0, 7)
+		}
+		return start.AddDate(0, 1, 0)
+	}
+	return start
+}
+
+func aggregateQuantities(values []float64, function string) (float64, error) {
+	switch function {
+	case "sum", "avg":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		if function == "avg" {
+			return sum / float64(len(values)), nil
+		}
+		return sum, nil
+	case "max":
+		max := math.Inf(-1)
+		for _, v := range values {
+			max = math.Max(max, v)
+		}
+		return max, nil
+	}
+	return 0, fmt.Errorf("unsupported function `%s`", function)
+}
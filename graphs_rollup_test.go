@@ -0,0 +1,130 @@
+package pi
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRollupGraphCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		exitCode int
+	}{
+		{"rollup graph - not specify source", []string{"graphs", "rollup", "--username", "c-know", "--target", "monthly"}, 1},
+		{"rollup graph - not specify target", []string{"graphs", "rollup", "--username", "c-know", "--source", "daily"}, 1},
+		{"rollup graph - invalid period", []string{"graphs", "rollup", "--username", "c-know", "--source", "daily", "--target", "monthly", "--period", "year"}, 1},
+		{"rollup graph - invalid func", []string{"graphs", "rollup", "--username", "c-know", "--source", "daily", "--target", "monthly", "--func", "min"}, 1},
+		{"rollup graph - invalid from", []string{"graphs", "rollup", "--username", "c-know", "--source", "daily", "--target", "monthly", "--from", "2019-01-01"}, 1},
+	}
+	for _, tt := range tests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestRollupPixels(t *testing.T) {
+	pixels := []pixel{
+		{Date: "20181231", Quantity: "1"},
+		{Date: "20190101", Quantity: "2"},
+		{Date: "20190106", Quantity: "3"},
+		{Date: "20190107", Quantity: "4"},
+		{Date: "20190201", Quantity: "0.5"},
+	}
+
+	tests := []struct {
+		period   string
+		function string
+		want     []dailyQuantity
+	}{
+		{"month", "sum", []dailyQuantity{{"20181201", 1}, {"20190101", 9}, {"20190201", 0.5}}},
+		{"month", "avg", []dailyQuantity{{"20181201", 1}, {"20190101", 3}, {"20190201", 0.5}}},
+		{"month", "max", []dailyQuantity{{"20181201", 1}, {"20190101", 4}, {"20190201", 0.5}}},
+		{"week", "sum", []dailyQuantity{{"20181231", 6}, {"20190107", 4}, {"20190128", 0.5}}},
+	}
+	for _, tt := range tests {
+		got, err := rollupPixels(pixels, tt.period, tt.function)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error. %s", tt.period, tt.function, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s: out=%v want=%v", tt.period, tt.function, got, tt.want)
+		}
+	}
+
+	if _, err := rollupPixels([]pixel{{Date: "20190101", Quantity: "many"}}, "month", "sum"); err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
+
+func TestRollupPeriodStart(t *testing.T) {
+	tests := []struct {
+		date   string
+		period string
+		want   string
+	}{
+		{"20190101", "week", "20181231"},
+		{"20190106", "week", "20181231"},
+		{"20190107", "week", "20190107"},
+		{"20190131", "month", "20190101"},
+	}
+	for _, tt := range tests {
+		d, _ := time.Parse(pixelDateLayout, tt.date)
+		if got := rollupPeriodStart(d, tt.period).Format(pixelDateLayout); got != tt.want {
+			t.Errorf("%s %s: out=%s want=%s", tt.date, tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultRollupFrom(t *testing.T) {
+	tests := []struct {
+		today  string
+		period string
+		want   string
+	}{
+		{"20190615", "month", "20180701"},
+		{"20190601", "month", "20180601"},
+		{"20190110", "week", "20180115"},
+		{"20190108", "week", "20180108"},
+	}
+	for _, tt := range tests {
+		d, _ := time.Parse(pixelDateLayout, tt.today)
+		if got := defaultRollupFrom(d, tt.period).Format(pixelDateLayout); got != tt.want {
+			t.Errorf("%s %s: out=%s want=%s", tt.today, tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestRollupPeriod(t *testing.T) {
+	today, _ := time.Parse(pixelDateLayout, "20190615")
+	tests := []struct {
+		from, to         string
+		wantFrom, wantTo string
+		wantErr          bool
+	}{
+		{"", "", "20180701", "20190615", false},
+		{"20170315", "", "20170301", "20190615", false},
+		{"20170315", "20171231", "20170301", "20171231", false},
+		{"", "20180630", "", "", true},
+		{"2017-03-15", "", "", "", true},
+		{"", "20191301", "", "", true},
+	}
+	for _, tt := range tests {
+		from, to, err := rollupPeriod(today, "month", tt.from, tt.to)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s-%s: unexpected error. %v", tt.from, tt.to, err)
+			continue
+		}
+		if from != tt.wantFrom || to != tt.wantTo {
+			t.Errorf("%s-%s: out=%s-%s want=%s-%s", tt.from, tt.to, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
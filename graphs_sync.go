@@ -0,0 +1,293 @@
+package pi
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type syncGraphCommand struct {
+	Username  string `short:"u" long:"username" description:"User name of graph owner."`
+	ID        string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	Source    string `long:"source" description:"CSV file of date (yyyyMMdd), quantity and optional optionalData columns. Specify - to read from stdin." required:"true"`
+	Mode      string `long:"mode" description:"upsert posts and updates the pixels of the source. mirror also deletes the pixels in the period which are not in the source. The pixels out of the period are kept." choice:"upsert" choice:"mirror" default:"upsert"`
+	From      string `short:"f" long:"from" description:"Specify the start position of the period to sync in yyyyMMdd format. The first date of the source by default."`
+	To        string `short:"t" long:"to" description:"Specify the end position of the period to sync in yyyyMMdd format. The last date of the source by default."`
+	DryRun    bool   `long:"dry-run" description:"Show the plan without updating the graph."`
+	Yes       bool   `long:"yes" description:"Delete the pixels which are not in the source in mirror mode."`
+	ChunkSize int    `long:"chunk-size" description:"The number of Pixels posted in one request." default:"100"`
+}
+
+// sourcePixel is a row of the source. optionalData is synced only when the cell is not empty,
+// since Pixela keeps the optionalData of an update without it, and a pixel could never be synced to an empty one.
+type sourcePixel struct {
+	pixel
+	hasOptionalData bool
+}
+
+// syncPlan is the minimal set of operations to make the graph match the source.
+type syncPlan struct {
+	Post      []postPixelParam
+	Update    []pixelUpdate
+	Delete    []pixel
+	Unchanged int
+}
+
+type pixelUpdate struct {
+	Before pixel
+	After  pixel
+}
+
+func (sG *syncGraphCommand) Execute(args []string) error {
+	username, err := getUsername(sG.Username)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if sG.Source != "-" {
+		f, err := os.Open(sG.Source)
+		if err != nil {
+			return fmt.Errorf("Failed to read source : %s", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	source, err := parseSyncSource(in)
+	if err != nil {
+		return err
+	}
+
+	from, to := syncPeriod(source, sG.From, sG.To)
+	source = filterSourcePixels(source, from, to)
+	if sG.Mode == "mirror" && len(source) == 0 {
+		return fmt.Errorf("source has no pixels in the period, so mirror mode would delete all pixels of the graph")
+	}
+
+	def, err := fetchGraphDefinition(username, sG.ID)
+	if err != nil {
+		return err
+	}
+	// the period of the source may be longer than a year.
+	var pixels []pixel
+	if from != "" && to != "" {
+		pixels, err = fetchGraphPixelsInWindows(username, sG.ID, from, to)
+	} else {
+		pixels, err = fetchGraphPixels(username, sG.ID, from, to)
+	}
+	if err != nil {
+		return err
+	}
+
+	plan, err := planSync(def, source, pixels, sG.Mode == "mirror")
+	if err != nil {
+		return err
+	}
+	printSyncPlan(os.Stdout, plan)
+	if sG.DryRun {
+		return nil
+	}
+	if len(plan.Delete) > 0 && !sG.Yes {
+		return fmt.Errorf("%d pixels would be deleted. Specify --yes to apply the plan", len(plan.Delete))
+	}
+	return applySyncPlan(sG, username, plan)
+}
+
+// parseSyncSource reads the rows of date, quantity and optionalData. A header row starting with `date` is skipped.
+func parseSyncSource(in io.Reader) ([]sourcePixel, error) {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse source : %s", err)
+	}
+
+	pixels := []sourcePixel{}
+	seen := map[string]bool{}
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			return nil, fmt.Errorf("line %d must have date, quantity and optionally optionalData", i+1)
+		}
+		p := sourcePixel{pixel: pixel{Date: strings.TrimSpace(record[0]), Quantity: strings.TrimSpace(record[1])}}
+		if _, err := time.Parse(pixelDateLayout, p.Date); err != nil {
+			return nil, fmt.Errorf("invalid date `%s` on line %d", p.Date, i+1)
+		}
+		if _, err := strconv.ParseFloat(p.Quantity, 64); err != nil {
+			return nil, fmt.Errorf("invalid quantity `%s` on line %d", p.Quantity, i+1)
+		}
+		if seen[p.Date] {
+			return nil, fmt.Errorf("duplicate date `%s` on line %d", p.Date, i+1)
+		}
+		seen[p.Date] = true
+		if len(record) == 3 && record[2] != "" {
+			p.OptionalData = record[2]
+			p.hasOptionalData = true
+		}
+		pixels = append(pixels, p)
+	}
+	return pixels, nil
+}
+
+// syncPeriod defaults the period to the dates of the source, so that the pixels out of the source are never fetched
+// and the period is not limited to the default period of the API.
+func syncPeriod(source []sourcePixel, from string, to string) (string, string) {
+	first, last := "", ""
+	for _, p := range source {
+		if first == "" || p.Date < first {
+			first = p.Date
+		}
+		if last == "" || p.Date > last {
+			last = p.Date
+		}
+	}
+	if from == "" {
+		from = first
+	}
+	if to == "" {
+		to = last
+	}
+	return from, to
+}
+
+func filterSourcePixels(source []sourcePixel, from string, to string) []sourcePixel {
+	filtered := []sourcePixel{}
+	for _, p := range source {
+		if (from != "" && p.Date < from) || (to != "" && p.Date > to) {
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+// planSync compares the source with the pixels of the graph. The quantities are compared as numbers,
+// and formatted for the type of the graph.
+func planSync(def *graphDefinition, source []sourcePixel, pixels []pixel, mirror bool) (*syncPlan, error) {
+	registered := map[string]pixel{}
+	for _, p := range pixels {
+		registered[p.Date] = p
+	}
+
+	plan := &syncPlan{Post: []postPixelParam{}, Update: []pixelUpdate{}, Delete: []pixel{}}
+	inSource := map[string]bool{}
+	for _, s := range source {
+		inSource[s.Date] = true
+		q, _ := strconv.ParseFloat(s.Quantity, 64)
+		if def.Type == "int" && q != math.Trunc(q) {
+			return nil, fmt.Errorf("quantity `%s` on %s is not an integer", s.Quantity, s.Date)
+		}
+		quantity := def.formatQuantity(q)
+
+		r, ok := registered[s.Date]
+		if !ok {
+			plan.Post = append(plan.Post, postPixelParam{Date: s.Date, Quantity: quantity, OptionalData: s.OptionalData})
+			continue
+		}
+		rq, err := strconv.ParseFloat(r.Quantity, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity `%s` on %s", r.Quantity, r.Date)
+		}
+		after := pixel{Date: s.Date, Quantity: quantity, OptionalData: r.OptionalData}
+		if s.hasOptionalData {
+			after.OptionalData = s.OptionalData
+		}
+		if rq == q && after.OptionalData == r.OptionalData {
+			plan.Unchanged++
+			continue
+		}
+		plan.Update = append(plan.Update, pixelUpdate{Before: r, After: after})
+	}
+
+	if mirror {
+		for _, p := range pixels {
+			if !inSource[p.Date] {
+				plan.Delete = append(plan.Delete, p)
+			}
+		}
+	}
+	sort.Slice(plan.Post, func(i, j int) bool { return plan.Post[i].Date < plan.Post[j].Date })
+	sort.Slice(plan.Update, func(i, j int) bool { return plan.Update[i].After.Date < plan.Update[j].After.Date })
+	return plan, nil
+}
+
+func (p *syncPlan) empty() bool {
+	return len(p.Post) == 0 && len(p.Update) == 0 && len(p.Delete) == 0
+}
+
+func printSyncPlan(out io.Writer, plan *syncPlan) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, p := range plan.Post {
+		fmt.Fprintf(w, "post\t%s\t%s\n", p.Date, p.Quantity)
+	}
+	for _, u := range plan.Update {
+		change := fmt.Sprintf("%s -> %s", u.Before.Quantity, u.After.Quantity)
+		if u.Before.OptionalData != u.After.OptionalData {
+			change += " (optionalData)"
+		}
+		fmt.Fprintf(w, "update\t%s\t%s\n", u.After.Date, change)
+	}
+	for _, p := range plan.Delete {
+		fmt.Fprintf(w, "delete\t%s\t%s\n", p.Date, p.Quantity)
+	}
+	w.Flush()
+	fmt.Fprintf(out, "%d to post, %d to update, %d to delete, %d unchanged\n", len(plan.Post), len(plan.Update), len(plan.Delete), plan.Unchanged)
+}
+
+// applySyncPlan posts the new pixels in batches, then updates and deletes the others one by one.
+func applySyncPlan(sG *syncGraphCommand, username string, plan *syncPlan) error {
+	if plan.empty() {
+		return nil
+	}
+	if len(plan.Post) > 0 {
+		err := postPixelsInBatches(&batchPixelsCommand{Username: username, ID: sG.ID, ChunkSize: sG.ChunkSize}, plan.Post)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, u := range plan.Update {
+		req, err := generateUpdatePixelRequest(&updatePixelCommand{
+			Username:     username,
+			ID:           sG.ID,
+			Date:         u.After.Date,
+			Quantity:     u.After.Quantity,
+			OptionalData: u.After.OptionalData,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = doRequestAndGetBody(req)
+		if err != nil {
+			return fmt.Errorf("Failed to update the pixel of %s : %s", u.After.Date, err)
+		}
+	}
+	if len(plan.Update) > 0 {
+		fmt.Printf("updated %d pixels\n", len(plan.Update))
+	}
+
+	for _, p := range plan.Delete {
+		req, err := generateDeletePixelRequest(&deletePixelCommand{Username: username, ID: sG.ID, Date: p.Date})
+		if err != nil {
+			return err
+		}
+		_, err = doRequestAndGetBody(req)
+		if err != nil {
+			return fmt.Errorf("Failed to delete the pixel of %s : %s", p.Date, err)
+		}
+	}
+	if len(plan.Delete) > 0 {
+		fmt.Printf("deleted %d pixels\n", len(plan.Delete))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package pi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncGraphCommand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-sync")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	empty := filepath.Join(dir, "empty.csv")
+	ioutil.WriteFile(empty, []byte("date,quantity\n"), 0600)
+	old := filepath.Join(dir, "old.csv")
+	ioutil.WriteFile(old, []byte("20180101,1\n"), 0600)
+
+	tests := []struct {
+		name     string
+		input    []string
+		exitCode int
+	}{
+		{"sync graph - not specify graph-id", []string{"graphs", "sync", "--username", "c-know", "--source", "data.csv"}, 1},
+		{"sync graph - not specify source", []string{"graphs", "sync", "--username", "c-know", "--graph-id", "test-id"}, 1},
+		{"sync graph - invalid mode", []string{"graphs", "sync", "--username", "c-know", "--graph-id", "test-id", "--source", "data.csv", "--mode", "replace"}, 1},
+		{"sync graph - source not found", []string{"graphs", "sync", "--username", "c-know", "--graph-id", "test-id", "--source", "/path/to/not-found.csv"}, 1},
+		{"sync graph - mirror empty source", []string{"graphs", "sync", "--username", "c-know", "--graph-id", "test-id", "--source", empty, "--mode", "mirror", "--yes"}, 1},
+		{"sync graph - mirror source out of period", []string{"graphs", "sync", "--username", "c-know", "--graph-id", "test-id", "--source", old, "--mode", "mirror", "--from", "20190101", "--yes"}, 1},
+	}
+	for _, tt := range tests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestParseSyncSource(t *testing.T) {
+	source, err := parseSyncSource(strings.NewReader("date,quantity,optionalData\n20190101,1,\n20190102, 2.5 ,\"{\"\"a\"\":1}\"\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if len(source) != 2 || source[1].Quantity != "2.5" || source[1].OptionalData != `{"a":1}` || source[0].hasOptionalData || !source[1].hasOptionalData {
+		t.Errorf("Unexpected source. %+v", source)
+	}
+
+	source, err = parseSyncSource(strings.NewReader("20190101,1\n"))
+	if err != nil || len(source) != 1 || source[0].hasOptionalData {
+		t.Errorf("Unexpected source. %+v %v", source, err)
+	}
+
+	// an empty cell keeps optionalData, so that the plan converges.
+	source, _ = parseSyncSource(strings.NewReader("20190101,1,\n"))
+	plan, err := planSync(&graphDefinition{ID: "test-id", Type: "int"}, source, []pixel{{Date: "20190101", Quantity: "1", OptionalData: `{"keep":true}`}}, false)
+	if err != nil || plan.Unchanged != 1 || !plan.empty() {
+		t.Errorf("empty optionalData should not be updated. %+v %v", plan, err)
+	}
+
+	invalids := []string{
+		"2019-01-01,1\n",
+		"20190101,many\n",
+		"20190101\n",
+		"20190101,1\n20190101,2\n",
+	}
+	for _, in := range invalids {
+		if _, err := parseSyncSource(strings.NewReader(in)); err == nil {
+			t.Errorf("expected error but not occurred. %q", in)
+		}
+	}
+}
+
+func TestSyncPeriod(t *testing.T) {
+	source := []sourcePixel{
+		{pixel: pixel{Date: "20190105"}},
+		{pixel: pixel{Date: "20180301"}},
+		{pixel: pixel{Date: "20190102"}},
+	}
+	tests := []struct {
+		from, to         string
+		wantFrom, wantTo string
+	}{
+		{"", "", "20180301", "20190105"},
+		{"20190101", "", "20190101", "20190105"},
+		{"", "20191231", "20180301", "20191231"},
+	}
+	for _, tt := range tests {
+		from, to := syncPeriod(source, tt.from, tt.to)
+		if from != tt.wantFrom || to != tt.wantTo {
+			t.Errorf("%s-%s: out=%s-%s want=%s-%s", tt.from, tt.to, from, to, tt.wantFrom, tt.wantTo)
+		}
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	def := &graphDefinition{ID: "test-id", Type: "int"}
+	source := []sourcePixel{
+		{pixel: pixel{Date: "20190101", Quantity: "1"}},
+		{pixel: pixel{Date: "20190102", Quantity: "2.0"}},
+		{pixel: pixel{Date: "20190103", Quantity: "3"}},
+		{pixel: pixel{Date: "20190104", Quantity: "4", OptionalData: `{"a":1}`}, hasOptionalData: true},
+	}
+	pixels := []pixel{
+		{Date: "20190102", Quantity: "2", OptionalData: `{"keep":true}`},
+		{Date: "20190103", Quantity: "5", OptionalData: `{"keep":true}`},
+		{Date: "20190104", Quantity: "4"},
+		{Date: "20190105", Quantity: "5"},
+	}
+
+	plan, err := planSync(def, source, pixels, false)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if len(plan.Post) != 1 || plan.Post[0].Date != "20190101" {
+		t.Errorf("Unexpected posts. %v", plan.Post)
+	}
+	if len(plan.Update) != 2 || plan.Update[0].After != (pixel{Date: "20190103", Quantity: "3", OptionalData: `{"keep":true}`}) || plan.Update[1].After.OptionalData != `{"a":1}` {
+		t.Errorf("Unexpected updates. %v", plan.Update)
+	}
+	if len(plan.Delete) != 0 || plan.Unchanged != 1 {
+		t.Errorf("upsert should not delete pixels. %+v", plan)
+	}
+
+	plan, err = planSync(def, source, pixels, true)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if len(plan.Delete) != 1 || plan.Delete[0].Date != "20190105" {
+		t.Errorf("Unexpected deletes. %v", plan.Delete)
+	}
+
+	out := &bytes.Buffer{}
+	printSyncPlan(out, plan)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		"post 20190101 1",
+		"update 20190103 5 -> 3",
+		"update 20190104 4 -> 4 (optionalData)",
+		"delete 20190105 5",
+		"1 to post, 2 to update, 1 to delete, 1 unchanged",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Unexpected plan. %s", out.String())
+	}
+	for i, l := range lines {
+		if got := strings.Join(strings.Fields(l), " "); got != want[i] {
+			t.Errorf("Unexpected line. out=%s want=%s", got, want[i])
+		}
+	}
+
+	_, err = planSync(def, []sourcePixel{{pixel: pixel{Date: "20190101", Quantity: "1.5"}}}, nil, false)
+	if err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
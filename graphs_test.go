@@ -1,7 +1,9 @@
 package pi
 
 import (
+	"bytes"
 	"fmt"
+	"image/png"
 	"io/ioutil"
 	"testing"
 )
@@ -71,6 +73,11 @@ var graphTests = []struct {
 		input:    []string{"graphs", "svg", "--username", "c-know"},
 		exitCode: 1,
 	},
+	{
+		name:     "get svg graph url - invalid format",
+		input:    []string{"graphs", "svg", "--username", "c-know", "--graph-id", "test-id", "--download", "out.gif", "--format", "gif"},
+		exitCode: 1,
+	},
 	{
 		name:     "update graph - not specify id",
 		input:    []string{"graphs", "update", "--name", "test-name", "--unit", "commits", "--color", "shibafu", "--username", "c-know", "--purge-cache-urls", "http://example.com/a", "--purge-cache-urls", "http://example.com/b"},
@@ -141,6 +148,26 @@ var graphTests = []struct {
 		input:    []string{"graphs", "stats", "--username", "c-know"},
 		exitCode: 1,
 	},
+	{
+		name:     "get graph svg - png of line mode",
+		input:    []string{"graphs", "svg", "--username", "c-know", "--graph-id", "test-id", "--mode", "line", "--download", "graph.png"},
+		exitCode: 1,
+	},
+	{
+		name:     "get graph svg - NaN scale",
+		input:    []string{"graphs", "svg", "--username", "c-know", "--graph-id", "test-id", "--download", "graph.png", "--scale", "NaN"},
+		exitCode: 1,
+	},
+	{
+		name:     "get graph definition - not specify id",
+		input:    []string{"graphs", "def", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "get graph definition - not specify username",
+		input:    []string{"graphs", "def", "--graph-id", "test-id"},
+		exitCode: 1,
+	},
 }
 
 func TestGraph(t *testing.T) {
@@ -546,6 +573,52 @@ func TestGenerateSVGUrlAllParamSpecified(t *testing.T) {
 	}
 }
 
+func TestSVGDownloadFormat(t *testing.T) {
+	tests := []struct {
+		download string
+		format   string
+		want     string
+	}{
+		{download: "out.svg", format: "", want: "svg"},
+		{download: "out.png", format: "", want: "png"},
+		{download: "OUT.PNG", format: "", want: "png"},
+		{download: "out", format: "", want: "svg"},
+		{download: "out.svg", format: "png", want: "png"},
+		{download: "out.png", format: "svg", want: "svg"},
+	}
+
+	for _, tt := range tests {
+		cmd := &graphSVGCommand{Download: tt.download, Format: tt.format}
+		if got := svgDownloadFormat(cmd); got != tt.want {
+			t.Errorf("Unexpected format for %s(%s). got=%s want=%s", tt.download, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestConvertSVG(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="4" height="2"><rect width="4" height="2" fill="#ff0000"/></svg>`)
+
+	b, err := convertSVG(svg, "svg", 1)
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if string(b) != string(svg) {
+		t.Errorf("Unexpected svg output. %s", string(b))
+	}
+
+	b, err = convertSVG(svg, "png", 2)
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Errorf("Failed to decode png. %s", err)
+	}
+	if img.Bounds().Dx() != 8 || img.Bounds().Dy() != 4 {
+		t.Errorf("Unexpected png size. %v", img.Bounds())
+	}
+}
+
 func TestGenerateUpdateGraphRequestWithSecretAndPublish(t *testing.T) {
 	// prepare
 	beforeAPIBaseEnv, beforeTokenEnv, _, _ := prepare()
@@ -1244,3 +1317,79 @@ func TestGenerateGetGraphStatsRequest(t *testing.T) {
 		t.Errorf("Unexpected request body. %s", string(b))
 	}
 }
+
+func TestGenerateGetGraphDefRequest(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+
+	testUsername := "c-know"
+	testID := "test-id"
+	cmd := &getGraphDefCommand{
+		Username: testUsername,
+		ID:       testID,
+	}
+
+	// run
+	req, err := generateGetGraphDefRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "GET" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/v1/users/%s/graphs/%s/graph-def", afterAPIBaseEnv, testUsername, testID) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		defer req.Body.Close()
+		if err != nil {
+			t.Errorf("Failed to read request body. %s", err)
+		}
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}
+
+func TestGenerateGetGraphPixelsRequestWithBody(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+
+	testUsername := "c-know"
+	testID := "test-id"
+	cmd := &getGraphPixelsCommand{
+		Username: testUsername,
+		ID:       testID,
+		From:     "20190101",
+		WithBody: true,
+	}
+
+	// run
+	req, err := generateGetGraphPixelsRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "GET" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/v1/users/%s/graphs/%s/pixels?from=20190101&withBody=true", afterAPIBaseEnv, testUsername, testID) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		defer req.Body.Close()
+		if err != nil {
+			t.Errorf("Failed to read request body. %s", err)
+		}
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}
@@ -0,0 +1,304 @@
+package pi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type hooksCommand struct {
+	Install   installHookCommand   `description:"install a git hook" command:"install" subcommands-optional:"true"`
+	Uninstall uninstallHookCommand `description:"uninstall a git hook" command:"uninstall" subcommands-optional:"true"`
+	List      listHooksCommand     `description:"list installed git hooks" command:"list" subcommands-optional:"true"`
+}
+
+type installHookCommand struct {
+	Username    string `short:"u" long:"username" description:"User name of graph owner."`
+	ID          string `short:"g" long:"graph-id" description:"ID of the graph to increment when the hook runs."`
+	WebhookHash string `short:"w" long:"webhookHash" description:"webhookHash of the registered webhook to invoke when the hook runs, instead of incrementing a graph."`
+	Event       string `short:"e" long:"event" description:"The git hook to install." default:"post-commit"`
+	Repo        string `short:"r" long:"repo" description:"Path of the git repository." default:"."`
+	PiPath      string `long:"pi-path" description:"Path of pi executable called from the hook. Defaults to the path of running pi."`
+}
+
+type uninstallHookCommand struct {
+	ID          string `short:"g" long:"graph-id" description:"ID of the graph of the hook to uninstall."`
+	WebhookHash string `short:"w" long:"webhookHash" description:"webhookHash of the hook to uninstall."`
+	Event       string `short:"e" long:"event" description:"The git hook to uninstall from." default:"post-commit"`
+	Repo        string `short:"r" long:"repo" description:"Path of the git repository." default:"."`
+}
+
+type listHooksCommand struct {
+	Repo string `short:"r" long:"repo" description:"Path of the git repository." default:"."`
+}
+
+const (
+	hookBlockBegin = "# >>> pi hook "
+	hookBlockEnd   = "# <<< pi hook "
+)
+
+func (iH *installHookCommand) Execute(args []string) error {
+	target, err := hookTarget(iH.ID, iH.WebhookHash)
+	if err != nil {
+		return err
+	}
+	username, err := getUsername(iH.Username)
+	if err != nil {
+		return err
+	}
+
+	piPath := iH.PiPath
+	if piPath == "" {
+		piPath, err = os.Executable()
+		if err != nil {
+			piPath = "pi"
+		}
+	}
+	var command string
+	if iH.ID != "" {
+		command = fmt.Sprintf("%s pixel increment -u %s -g %s", shellQuote(piPath), shellQuote(username), shellQuote(iH.ID))
+	} else {
+		command = fmt.Sprintf("%s webhooks invoke -u %s -w %s", shellQuote(piPath), shellQuote(username), shellQuote(iH.WebhookHash))
+	}
+
+	path, err := hookPath(iH.Repo, iH.Event)
+	if err != nil {
+		return err
+	}
+	script, err := readHook(path)
+	if err != nil {
+		return err
+	}
+
+	script, err = addHookBlock(script, target, command)
+	if err != nil {
+		return fmt.Errorf("Failed to install to %s : %s", path, err)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("Failed to create hooks directory : %s", err)
+	}
+	err = ioutil.WriteFile(path, []byte(script), 0755)
+	if err != nil {
+		return fmt.Errorf("Failed to write hook : %s", err)
+	}
+	// WriteFile does not change the permission of the existing file.
+	err = os.Chmod(path, 0755)
+	if err != nil {
+		return fmt.Errorf("Failed to change permission of hook : %s", err)
+	}
+	fmt.Printf("installed %s to %s\n", target, path)
+	return nil
+}
+
+func (uH *uninstallHookCommand) Execute(args []string) error {
+	target, err := hookTarget(uH.ID, uH.WebhookHash)
+	if err != nil {
+		return err
+	}
+
+	path, err := hookPath(uH.Repo, uH.Event)
+	if err != nil {
+		return err
+	}
+	script, err := readHook(path)
+	if err != nil {
+		return err
+	}
+
+	script, removed := removeHookBlock(script, target)
+	if !removed {
+		return fmt.Errorf("%s is not installed in %s", target, path)
+	}
+
+	// remove the hook created by pi if nothing is left.
+	if strings.TrimSpace(strings.TrimPrefix(script, "#!/bin/sh")) == "" {
+		err = os.Remove(path)
+	} else {
+		err = ioutil.WriteFile(path, []byte(script), 0755)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to write hook : %s", err)
+	}
+	fmt.Printf("uninstalled %s from %s\n", target, path)
+	return nil
+}
+
+func (lH *listHooksCommand) Execute(args []string) error {
+	dir, err := hooksDir(lH.Repo)
+	if err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to read hooks directory : %s", err)
+	}
+
+	for _, f := range files {
+		if f.IsDir() || strings.HasSuffix(f.Name(), ".sample") {
+			continue
+		}
+		script, err := readHook(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		blocks := listHookBlocks(script)
+		targets := make([]string, 0, len(blocks))
+		for target := range blocks {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+		for _, target := range targets {
+			fmt.Printf("%s\t%s\t%s\n", f.Name(), target, blocks[target])
+		}
+	}
+	return nil
+}
+
+func hookTarget(id string, webhookHash string) (string, error) {
+	if (id == "") == (webhookHash == "") {
+		return "", fmt.Errorf("specify either --graph-id,-g or --webhookHash,-w")
+	}
+	if id != "" {
+		return fmt.Sprintf("graph:%s", id), nil
+	}
+	return fmt.Sprintf("webhook:%s", webhookHash), nil
+}
+
+// hooksDir asks git for the hooks directory, so that `core.hooksPath` and worktrees are respected.
+func hooksDir(repo string) (string, error) {
+	out, err := exec.Command("git", "-C", repo, "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("Failed to find hooks directory : %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("Failed to find hooks directory : %s", err)
+	}
+	dir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repo, dir)
+	}
+	return dir, nil
+}
+
+func hookPath(repo string, event string) (string, error) {
+	if event == "" || strings.ContainsAny(event, `/\`) {
+		return "", fmt.Errorf("invalid hook event `%s`", event)
+	}
+	dir, err := hooksDir(repo)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, event), nil
+}
+
+// readHook returns an empty string if the hook does not exist.
+func readHook(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to read hook : %s", err)
+	}
+	return string(b), nil
+}
+
+// shellInterpreters are the interpreters which can run the block written in sh.
+var shellInterpreters = []string{"sh", "bash", "dash", "ksh", "zsh"}
+
+// addHookBlock adds the command to the hook script, keeping the existing content.
+// The block is inserted before the trailing `exit` or `exec` of the script so that it is reached.
+// Hooks written in other languages than sh are refused.
+func addHookBlock(script string, target string, command string) (string, error) {
+	script, _ = removeHookBlock(script, target)
+	if script == "" {
+		script = "#!/bin/sh\n"
+	}
+	if interpreter := hookInterpreter(script); interpreter != "" && !containsString(shellInterpreters, interpreter) {
+		return "", fmt.Errorf("the existing hook is run by `%s`, not by sh. add the command to it manually", interpreter)
+	}
+
+	block := fmt.Sprintf("%s%s >>>\n%s >/dev/null || echo \"pi: failed to update %s\" >&2\n%s%s <<<\n",
+		hookBlockBegin, target, command, target, hookBlockEnd, target)
+
+	lines := strings.Split(strings.TrimRight(script, "\n"), "\n")
+	last := lines[len(lines)-1]
+	if len(lines) > 1 && (last == "exit" || strings.HasPrefix(last, "exit ") || strings.HasPrefix(last, "exec ")) {
+		return strings.Join(lines[:len(lines)-1], "\n") + "\n" + block + last + "\n", nil
+	}
+	return strings.Join(lines, "\n") + "\n" + block, nil
+}
+
+// hookInterpreter returns the name of the interpreter in the shebang, such as `sh` of `#!/bin/sh` or `python3` of `#!/usr/bin/env python3`.
+// A script without shebang is run by sh, so an empty string is returned.
+func hookInterpreter(script string) string {
+	if !strings.HasPrefix(script, "#!") {
+		return ""
+	}
+	fields := strings.Fields(strings.SplitN(script[2:], "\n", 2)[0])
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				return filepath.Base(f)
+			}
+		}
+	}
+	return interpreter
+}
+
+func removeHookBlock(script string, target string) (string, bool) {
+	begin := fmt.Sprintf("%s%s >>>", hookBlockBegin, target)
+	end := fmt.Sprintf("%s%s <<<", hookBlockEnd, target)
+
+	result := []string{}
+	inBlock, removed := false, false
+	for _, line := range strings.SplitAfter(script, "\n") {
+		switch {
+		case strings.TrimRight(line, "\n") == begin:
+			inBlock, removed = true, true
+		case inBlock && strings.TrimRight(line, "\n") == end:
+			inBlock = false
+		case !inBlock:
+			result = append(result, line)
+		}
+	}
+	return strings.Join(result, ""), removed
+}
+
+// listHookBlocks returns the commands installed by pi, keyed by their target.
+func listHookBlocks(script string) map[string]string {
+	blocks := map[string]string{}
+	target := ""
+	for _, line := range strings.Split(script, "\n") {
+		switch {
+		case strings.HasPrefix(line, hookBlockBegin) && strings.HasSuffix(line, " >>>"):
+			target = strings.TrimSuffix(strings.TrimPrefix(line, hookBlockBegin), " >>>")
+		case strings.HasPrefix(line, hookBlockEnd):
+			target = ""
+		case target != "":
+			blocks[target] = strings.SplitN(line, " >/dev/null", 2)[0]
+		}
+	}
+	return blocks
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:@", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,169 @@
+package pi
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var hooksTests = []struct {
+	name     string
+	input    []string
+	exitCode int
+}{
+	{
+		name:     "install hook - not specify id and webhookHash",
+		input:    []string{"hooks", "install", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "install hook - specify both id and webhookHash",
+		input:    []string{"hooks", "install", "--username", "c-know", "--graph-id", "test-id", "--webhookHash", "hash"},
+		exitCode: 1,
+	},
+	{
+		name:     "uninstall hook - not specify id and webhookHash",
+		input:    []string{"hooks", "uninstall"},
+		exitCode: 1,
+	},
+}
+
+func TestHooks(t *testing.T) {
+	for _, tt := range hooksTests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestAddHookBlock(t *testing.T) {
+	script, err := addHookBlock("", "graph:test-id", "pi pixel increment -u c-know -g test-id")
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	want := `#!/bin/sh
+# >>> pi hook graph:test-id >>>
+pi pixel increment -u c-know -g test-id >/dev/null || echo "pi: failed to update graph:test-id" >&2
+# <<< pi hook graph:test-id <<<
+`
+	if script != want {
+		t.Errorf("Unexpected script. %s", script)
+	}
+
+	// installing again replaces the block.
+	if again, _ := addHookBlock(script, "graph:test-id", "pi pixel increment -u c-know -g test-id"); again != want {
+		t.Errorf("Unexpected script after reinstall. %s", again)
+	}
+
+	existing := "#!/bin/bash\necho existing\nexit 0\n"
+	script, _ = addHookBlock(existing, "webhook:hash", "pi webhooks invoke -u c-know -w hash")
+	if !strings.HasPrefix(script, "#!/bin/bash\necho existing\n# >>> pi hook webhook:hash >>>\n") || !strings.HasSuffix(script, "# <<< pi hook webhook:hash <<<\nexit 0\n") {
+		t.Errorf("Unexpected script with existing hook. %s", script)
+	}
+
+	blocks := listHookBlocks(script)
+	if len(blocks) != 1 || blocks["webhook:hash"] != "pi webhooks invoke -u c-know -w hash" {
+		t.Errorf("Unexpected blocks. %v", blocks)
+	}
+
+	removed, ok := removeHookBlock(script, "webhook:hash")
+	if !ok || removed != existing {
+		t.Errorf("Unexpected script after remove. %s", removed)
+	}
+	if _, ok := removeHookBlock(existing, "webhook:hash"); ok {
+		t.Errorf("block should not be found")
+	}
+
+	script, _ = addHookBlock("#!/usr/bin/env bash\nexec other-hook \"$@\"\n", "graph:test-id", "pi pixel increment -u c-know -g test-id")
+	if !strings.HasSuffix(script, "# <<< pi hook graph:test-id <<<\nexec other-hook \"$@\"\n") {
+		t.Errorf("block should be inserted before exec. %s", script)
+	}
+
+	for _, hook := range []string{"#!/usr/bin/env python3\nprint('hi')\n", "#!/usr/bin/node\nconsole.log('hi')\n"} {
+		if _, err := addHookBlock(hook, "graph:test-id", "pi pixel increment -u c-know -g test-id"); err == nil {
+			t.Errorf("expected error but not occurred. %s", hook)
+		}
+	}
+}
+
+func TestHookInterpreter(t *testing.T) {
+	tests := map[string]string{
+		"#!/bin/sh\n":                     "sh",
+		"#!/bin/bash -e\necho\n":          "bash",
+		"#!/usr/bin/env python3\n":        "python3",
+		"#!/usr/bin/env -S node --flag\n": "node",
+		"echo no shebang\n":               "",
+	}
+	for script, want := range tests {
+		if got := hookInterpreter(script); got != want {
+			t.Errorf("%q: out=%s want=%s", script, got, want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"/usr/local/bin/pi": "/usr/local/bin/pi",
+		"c-know":            "c-know",
+		"":                  "''",
+		"my pi":             "'my pi'",
+		"it's":              `'it'\''s'`,
+	}
+	for input, want := range tests {
+		if got := shellQuote(input); got != want {
+			t.Errorf("Unexpected quote for %s. got=%s want=%s", input, got, want)
+		}
+	}
+}
+
+func TestInstallAndUninstallHook(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo, err := ioutil.TempDir("", "pi-hooks")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(repo)
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to init repository. %s", out)
+	}
+	hook := filepath.Join(repo, ".git", "hooks", "post-commit")
+	err = ioutil.WriteFile(hook, []byte("#!/bin/sh\necho existing\n"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write hook. %s", err)
+	}
+
+	err = (&installHookCommand{Username: "c-know", ID: "test-id", Event: "post-commit", Repo: repo, PiPath: "pi"}).Execute(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	b, _ := ioutil.ReadFile(hook)
+	if !strings.Contains(string(b), "echo existing\n") || !strings.Contains(string(b), "pi pixel increment -u c-know -g test-id") {
+		t.Errorf("Unexpected hook. %s", string(b))
+	}
+	if info, _ := os.Stat(hook); info.Mode().Perm()&0100 == 0 {
+		t.Errorf("hook is not executable. %s", info.Mode())
+	}
+
+	err = (&uninstallHookCommand{ID: "test-id", Event: "post-commit", Repo: repo}).Execute(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	b, _ = ioutil.ReadFile(hook)
+	if string(b) != "#!/bin/sh\necho existing\n" {
+		t.Errorf("Unexpected hook after uninstall. %s", string(b))
+	}
+
+	err = (&uninstallHookCommand{ID: "test-id", Event: "post-commit", Repo: repo}).Execute(nil)
+	if err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
@@ -0,0 +1,185 @@
+package pi
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type integrateCommand struct {
+	Git integrateGitCommand `description:"count commits of a git repository" command:"git" subcommands-optional:"true"`
+}
+
+type integrateGitCommand struct {
+	Username   string `short:"u" long:"username" description:"User name of graph owner."`
+	ID         string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	Repo       string `short:"r" long:"repo" description:"Path of the git repository." default:"."`
+	Author     string `short:"a" long:"author" description:"Count only commits whose author matches this pattern (passed to git log --author)."`
+	Since      string `short:"s" long:"since" description:"The period to count. Specify as number of days (30d), weeks (4w) or a date in yyyyMMdd format." default:"30d"`
+	AllBranch  bool   `long:"all" description:"Count commits of all branches instead of HEAD."`
+	NoMerges   bool   `long:"no-merges" description:"Do not count merge commits."`
+	Idempotent bool   `long:"idempotent" description:"Fetch registered pixels and update only the dates whose quantity differs. Suitable for post-commit hooks."`
+	DryRun     bool   `long:"dry-run" description:"Show the quantities without updating the graph."`
+	ChunkSize  int    `long:"chunk-size" description:"The number of Pixels posted in one request." default:"100"`
+}
+
+type dailyQuantity struct {
+	Date     string
+	Quantity float64
+}
+
+func (iG *integrateGitCommand) Execute(args []string) error {
+	username, err := getUsername(iG.Username)
+	if err != nil {
+		return err
+	}
+
+	def, err := fetchGraphDefinition(username, iG.ID)
+	if err != nil {
+		return err
+	}
+	loc, err := def.location()
+	if err != nil {
+		return err
+	}
+	today, err := def.today()
+	if err != nil {
+		return err
+	}
+	since, err := parseSince(iG.Since, today)
+	if err != nil {
+		return err
+	}
+
+	log, err := gitLog(iG, since)
+	if err != nil {
+		return err
+	}
+	counts, err := countCommitsPerDay(log, loc, since, today)
+	if err != nil {
+		return err
+	}
+
+	if iG.Idempotent {
+		pixels, err := fetchGraphPixels(username, iG.ID, since.Format(pixelDateLayout), today.Format(pixelDateLayout))
+		if err != nil {
+			return err
+		}
+		counts, err = changedQuantities(counts, pixels)
+		if err != nil {
+			return err
+		}
+	}
+
+	pixels := []postPixelParam{}
+	for _, c := range counts {
+		quantity := def.formatQuantity(c.Quantity)
+		fmt.Printf("%s\t%s\n", c.Date, quantity)
+		pixels = append(pixels, postPixelParam{Date: c.Date, Quantity: quantity})
+	}
+	if iG.DryRun || len(pixels) == 0 {
+		return nil
+	}
+	return postPixelsInBatches(&batchPixelsCommand{Username: username, ID: iG.ID, ChunkSize: iG.ChunkSize}, pixels)
+}
+
+// parseSince returns the first day of the period specified as `30d`, `4w` or `yyyyMMdd`.
+func parseSince(since string, today time.Time) (time.Time, error) {
+	if len(since) > 1 && (strings.HasSuffix(since, "d") || strings.HasSuffix(since, "w")) {
+		n, err := strconv.Atoi(since[:len(since)-1])
+		if err == nil && n > 0 {
+			if strings.HasSuffix(since, "w") {
+				n *= 7
+			}
+			return today.AddDate(0, 0, -(n - 1)), nil
+		}
+	}
+
+	t, err := time.ParseInLocation(pixelDateLayout, since, today.Location())
+	if err != nil {
+		return t, fmt.Errorf("invalid period `%s`. It must be specified as number of days (30d), weeks (4w) or a date in yyyyMMdd format", since)
+	}
+	return t, nil
+}
+
+func gitLog(iG *integrateGitCommand, since time.Time) (string, error) {
+	args := []string{
+		"-C", iG.Repo,
+		"log",
+		"--format=%aI",
+		// committer date filter is applied by git. author date is filtered again after that.
+		fmt.Sprintf("--since=%s", since.AddDate(0, 0, -1).Format(time.RFC3339)),
+	}
+	if iG.Author != "" {
+		args = append(args, fmt.Sprintf("--author=%s", iG.Author))
+	}
+	if iG.AllBranch {
+		args = append(args, "--all")
+	}
+	if iG.NoMerges {
+		args = append(args, "--no-merges")
+	}
+
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("Failed to run git log : %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("Failed to run git log : %s", err)
+	}
+	return string(out), nil
+}
+
+// countCommitsPerDay counts author dates (one RFC3339 timestamp per line) by day in `loc`.
+func countCommitsPerDay(log string, loc *time.Location, since time.Time, until time.Time) ([]dailyQuantity, error) {
+	counts := map[string]float64{}
+	from := since.Format(pixelDateLayout)
+	to := until.Format(pixelDateLayout)
+
+	scanner := bufio.NewScanner(strings.NewReader(log))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, line)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse git log : %s", err)
+		}
+		date := t.In(loc).Format(pixelDateLayout)
+		if date < from || date > to {
+			continue
+		}
+		counts[date]++
+	}
+
+	result := []dailyQuantity{}
+	for date, count := range counts {
+		result = append(result, dailyQuantity{Date: date, Quantity: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date < result[j].Date
+	})
+	return result, nil
+}
+
+// changedQuantities drops the quantities which are already registered as pixels.
+func changedQuantities(quantities []dailyQuantity, pixels []pixel) ([]dailyQuantity, error) {
+	registered, err := pixelQuantities(pixels)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []dailyQuantity{}
+	for _, q := range quantities {
+		if r, ok := registered[q.Date]; ok && r == q.Quantity {
+			continue
+		}
+		result = append(result, q)
+	}
+	return result, nil
+}
@@ -0,0 +1,113 @@
+package pi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+var integrateTests = []struct {
+	name     string
+	input    []string
+	exitCode int
+}{
+	{
+		name:     "integrate git - not specify id",
+		input:    []string{"integrate", "git", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "integrate git - not specify username",
+		input:    []string{"integrate", "git", "--graph-id", "test-id"},
+		exitCode: 1,
+	},
+}
+
+func TestIntegrate(t *testing.T) {
+	for _, tt := range integrateTests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	today := time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		since string
+		want  string
+	}{
+		{since: "1d", want: "20190310"},
+		{since: "30d", want: "20190209"},
+		{since: "2w", want: "20190225"},
+		{since: "20190101", want: "20190101"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSince(tt.since, today)
+		if err != nil {
+			t.Errorf("Unexpected error occurs for %s. %s", tt.since, err)
+		}
+		if got.Format(pixelDateLayout) != tt.want {
+			t.Errorf("Unexpected since for %s. got=%s want=%s", tt.since, got.Format(pixelDateLayout), tt.want)
+		}
+	}
+
+	for _, since := range []string{"0d", "d", "30", "2019-01-01", "1m"} {
+		if _, err := parseSince(since, today); err == nil {
+			t.Errorf("expected error for %s but not occurred", since)
+		}
+	}
+}
+
+func TestCountCommitsPerDay(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Failed to load location. %s", err)
+	}
+	since := time.Date(2019, 1, 1, 0, 0, 0, 0, loc)
+	until := time.Date(2019, 1, 3, 0, 0, 0, 0, loc)
+	log := `2019-01-03T10:00:00+09:00
+2019-01-02T16:00:00+00:00
+2019-01-02T14:00:00+00:00
+2019-01-01T09:00:00+09:00
+
+2018-12-31T14:59:59Z
+2019-01-04T00:00:00+09:00
+`
+
+	counts, err := countCommitsPerDay(log, loc, since, until)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	want := []dailyQuantity{{"20190101", 1}, {"20190102", 1}, {"20190103", 2}}
+	if fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Errorf("Unexpected counts. %v", counts)
+	}
+
+	if _, err := countCommitsPerDay("yesterday", loc, since, until); err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
+
+func TestChangedQuantities(t *testing.T) {
+	quantities := []dailyQuantity{{"20190101", 1}, {"20190102", 2}, {"20190103", 3}}
+	pixels := []pixel{
+		{Date: "20190101", Quantity: "1"},
+		{Date: "20190102", Quantity: "1"},
+	}
+
+	changed, err := changedQuantities(quantities, pixels)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	want := []dailyQuantity{{"20190102", 2}, {"20190103", 3}}
+	if fmt.Sprint(changed) != fmt.Sprint(want) {
+		t.Errorf("Unexpected quantities. %v", changed)
+	}
+}
@@ -0,0 +1,166 @@
+package pi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// multiGraphWorkers is the number of requests sent concurrently for multiple graphs.
+const multiGraphWorkers = 4
+
+type graphResult struct {
+	ID   string
+	Body []byte
+	Err  error
+}
+
+// isMultiGraph reports whether `-g` specifies multiple graphs. These characters are never part of a graph ID.
+func isMultiGraph(id string) bool {
+	return strings.ContainsAny(id, ",*?[/")
+}
+
+// resolveGraphIDs expands the comma separated terms of `-g`. The graph IDs are fetched only for a glob or a regular expression.
+func resolveGraphIDs(pattern string, fetchIDs func() ([]string, error)) ([]string, error) {
+	var all []string
+	ids := []string{}
+	seen := map[string]bool{}
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, term := range strings.Split(pattern, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		if !strings.ContainsAny(term, "*?[/") {
+			add(term)
+			continue
+		}
+
+		match, err := graphIDMatcher(term)
+		if err != nil {
+			return nil, err
+		}
+		if all == nil {
+			all, err = fetchIDs()
+			if err != nil {
+				return nil, err
+			}
+		}
+		matched := false
+		for _, id := range all {
+			if match(id) {
+				add(id)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no graphs match `%s`", term)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no graphs are specified")
+	}
+	return ids, nil
+}
+
+func graphIDMatcher(term string) (func(id string) bool, error) {
+	if len(term) > 2 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/") {
+		re, err := regexp.Compile(term[1 : len(term)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression `%s` : %s", term, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(term, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern `%s` : %s", term, err)
+	}
+	return func(id string) bool {
+		ok, _ := path.Match(term, id)
+		return ok
+	}, nil
+}
+
+// doMultiGraphRequests sends the requests for each graph matching `pattern`, and reports the result of each graph.
+func doMultiGraphRequests(cmdUsername string, pattern string, generate func(id string) (*http.Request, error)) error {
+	username, err := getUsername(cmdUsername)
+	if err != nil {
+		return err
+	}
+	ids, err := resolveGraphIDs(pattern, func() ([]string, error) {
+		defs, err := fetchGraphDefinitions(username)
+		if err != nil {
+			return nil, err
+		}
+		ids := []string{}
+		for _, d := range defs {
+			ids = append(ids, d.ID)
+		}
+		return ids, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	results := runForGraphs(ids, multiGraphWorkers, func(id string) ([]byte, error) {
+		req, err := generate(id)
+		if err != nil {
+			return nil, err
+		}
+		return doRequestAndGetBody(req)
+	})
+	return reportGraphResults(os.Stdout, results)
+}
+
+// runForGraphs runs `f` for the graphs with at most `workers` goroutines. The results are in the order of `ids`.
+func runForGraphs(ids []string, workers int, f func(id string) ([]byte, error)) []graphResult {
+	results := make([]graphResult, len(ids))
+	queue := make(chan int)
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				b, err := f(ids[i])
+				results[i] = graphResult{ID: ids[i], Body: b, Err: err}
+			}
+		}()
+	}
+	for i := range ids {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+func reportGraphResults(out io.Writer, results []graphResult) error {
+	failed := 0
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\tfailed\t%s\n", r.ID, strings.TrimSpace(r.Err.Error()))
+			continue
+		}
+		fmt.Fprintf(w, "%s\tok\t%s\n", r.ID, strings.TrimSpace(string(r.Body)))
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d graphs failed", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package pi
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIsMultiGraph(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"test-graph", false},
+		{"graph-a,graph-b", true},
+		{"team-*", true},
+		{"team-?", true},
+		{"/^team-/", true},
+	}
+	for _, tt := range tests {
+		if got := isMultiGraph(tt.id); got != tt.want {
+			t.Errorf("isMultiGraph(%s): out=%v want=%v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestResolveGraphIDs(t *testing.T) {
+	fetched := 0
+	fetchIDs := func() ([]string, error) {
+		fetched++
+		return []string{"team-a", "team-b", "private", "team-c2"}, nil
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+		fetched int
+		isErr   bool
+	}{
+		{"graph-a, graph-b,", []string{"graph-a", "graph-b"}, 0, false},
+		{"team-*", []string{"team-a", "team-b", "team-c2"}, 1, false},
+		{"private,team-?", []string{"private", "team-a", "team-b"}, 1, false},
+		{"/[0-9]$/,team-*", []string{"team-c2", "team-a", "team-b"}, 1, false},
+		{"other-*", nil, 1, true},
+		{"/[/", nil, 0, true},
+		{"team-[", nil, 0, true},
+		{",", nil, 0, true},
+	}
+	for _, tt := range tests {
+		fetched = 0
+		got, err := resolveGraphIDs(tt.pattern, fetchIDs)
+		if tt.isErr != (err != nil) {
+			t.Errorf("%s: unexpected error. %v", tt.pattern, err)
+			continue
+		}
+		if !tt.isErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: out=%v want=%v", tt.pattern, got, tt.want)
+		}
+		if fetched != tt.fetched {
+			t.Errorf("%s: fetched %d times want=%d", tt.pattern, fetched, tt.fetched)
+		}
+	}
+
+	_, err := resolveGraphIDs("team-*", func() ([]string, error) { return nil, errors.New("unavailable") })
+	if err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
+
+func TestRunForGraphs(t *testing.T) {
+	ids := []string{"graph-a", "graph-b", "graph-c", "graph-d", "graph-e"}
+	mu := &sync.Mutex{}
+	running, maxRunning := 0, 0
+	results := runForGraphs(ids, 2, func(id string) ([]byte, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if id == "graph-c" {
+			return nil, errors.New("404 Not Found")
+		}
+		return []byte(id), nil
+	})
+
+	if maxRunning > 2 {
+		t.Errorf("too many workers. %d", maxRunning)
+	}
+	for i, r := range results {
+		if r.ID != ids[i] {
+			t.Errorf("results should be in order. %v", results)
+		}
+		if (r.Err != nil) != (r.ID == "graph-c") {
+			t.Errorf("%s: unexpected error. %v", r.ID, r.Err)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	err := reportGraphResults(out, results)
+	if err == nil || err.Error() != "1 of 5 graphs failed" {
+		t.Errorf("Unexpected error. %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Unexpected report. %s", out.String())
+	}
+	if got := strings.Join(strings.Fields(lines[2]), " "); got != "graph-c failed 404 Not Found" {
+		t.Errorf("Unexpected report line. %s", got)
+	}
+	if got := strings.Join(strings.Fields(lines[0]), " "); got != "graph-a ok graph-a" {
+		t.Errorf("Unexpected report line. %s", got)
+	}
+
+	if err := reportGraphResults(&bytes.Buffer{}, results[:2]); err != nil {
+		t.Errorf("Unexpected error. %s", err)
+	}
+}
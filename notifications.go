@@ -6,10 +6,11 @@ import (
 )
 
 type notificationsCommand struct {
-	Post   postNotificationCommand   `description:"post Notification setting" command:"create" subcommands-optional:"true"`
-	Put    putNotificationCommand    `description:"update Notification setting" command:"update" subcommands-optional:"true"`
-	Get    getNotificationsCommand   `description:"get Notifications" command:"get" subcommands-optional:"true"`
-	Delete deleteNotificationCommand `description:"delete Notification" command:"delete" subcommands-optional:"true"`
+	Post     postNotificationCommand      `description:"post Notification setting" command:"create" subcommands-optional:"true"`
+	Put      putNotificationCommand       `description:"update Notification setting" command:"update" subcommands-optional:"true"`
+	Get      getNotificationsCommand      `description:"get Notifications" command:"get" subcommands-optional:"true"`
+	Delete   deleteNotificationCommand    `description:"delete Notification" command:"delete" subcommands-optional:"true"`
+	Simulate simulateNotificationsCommand `description:"replay Notifications over past Pixels" command:"simulate" subcommands-optional:"true"`
 }
 
 type getNotificationsCommand struct {
@@ -0,0 +1,181 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+type simulateNotificationsCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	GraphID  string `short:"g" long:"graph-id" description:"ID for identifying the graph." required:"true"`
+	ID       string `short:"i" long:"notifiation-id" description:"Simulate only the notification setting of this ID."`
+	From     string `short:"f" long:"from" description:"Specify the start position of the period in yyyyMMdd format. Defaults to a year before --to."`
+	To       string `short:"t" long:"to" description:"Specify the end position of the period in yyyyMMdd format. Defaults to today in the graph's timezone."`
+	Format   string `long:"format" description:"Output format." choice:"table" choice:"json" default:"table"`
+}
+
+type notificationRule struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Target    string `json:"target"`
+	Condition string `json:"condition"`
+	Threshold string `json:"threshold"`
+	ChannelID string `json:"channelID"`
+}
+
+type notificationRules struct {
+	Notifications []notificationRule `json:"notifications"`
+}
+
+type notificationSimulation struct {
+	notificationRule
+	Fired   []pixel `json:"fired"`
+	Skipped string  `json:"skipped,omitempty"`
+}
+
+func (sN *simulateNotificationsCommand) Execute(args []string) error {
+	username, err := getUsername(sN.Username)
+	if err != nil {
+		return err
+	}
+
+	rules, err := fetchNotificationRules(username, sN.GraphID)
+	if err != nil {
+		return err
+	}
+	if sN.ID != "" {
+		filtered := []notificationRule{}
+		for _, r := range rules {
+			if r.ID == sN.ID {
+				filtered = append(filtered, r)
+			}
+		}
+		if len(filtered) == 0 {
+			return fmt.Errorf("notification `%s` is not found in graph `%s`", sN.ID, sN.GraphID)
+		}
+		rules = filtered
+	}
+
+	def, err := fetchGraphDefinition(username, sN.GraphID)
+	if err != nil {
+		return err
+	}
+	// the pixels are fetched by year, so that a period longer than a year is not cut short by the API.
+	from, to, err := graphPixelPeriod(def, sN.From, sN.To)
+	if err != nil {
+		return err
+	}
+	pixels, err := fetchGraphPixelsInWindows(username, sN.GraphID, from, to)
+	if err != nil {
+		return err
+	}
+	simulations, err := simulateNotifications(rules, pixels)
+	if err != nil {
+		return err
+	}
+
+	if sN.Format == "json" {
+		return json.NewEncoder(os.Stdout).Encode(simulations)
+	}
+	printNotificationSimulations(os.Stdout, simulations)
+	return nil
+}
+
+func fetchNotificationRules(username string, graphID string) ([]notificationRule, error) {
+	req, err := generateGetNotificationsRequest(&getNotificationsCommand{Username: username, GraphID: graphID})
+	if err != nil {
+		return nil, err
+	}
+	b, err := doRequestAndGetBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &notificationRules{}
+	err = json.Unmarshal(b, rules)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse notifications : %s", err)
+	}
+	return rules.Notifications, nil
+}
+
+// simulateNotifications replays the rules over the final quantity of each pixel.
+// Pixela evaluates the rules whenever a pixel is updated, so the intermediate quantities of a day are not reproduced.
+func simulateNotifications(rules []notificationRule, pixels []pixel) ([]*notificationSimulation, error) {
+	simulations := make([]*notificationSimulation, 0, len(rules))
+	for _, r := range rules {
+		s := &notificationSimulation{notificationRule: r, Fired: []pixel{}}
+		simulations = append(simulations, s)
+		if r.Target != "quantity" {
+			s.Skipped = fmt.Sprintf("target `%s` is not supported", r.Target)
+			continue
+		}
+		threshold, err := strconv.ParseFloat(r.Threshold, 64)
+		if err != nil {
+			s.Skipped = fmt.Sprintf("invalid threshold `%s`", r.Threshold)
+			continue
+		}
+
+		for _, p := range pixels {
+			q, err := strconv.ParseFloat(p.Quantity, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quantity `%s` on %s", p.Quantity, p.Date)
+			}
+			fired, err := matchNotificationCondition(r.Condition, q, threshold)
+			if err != nil {
+				s.Skipped = err.Error()
+				break
+			}
+			if fired {
+				s.Fired = append(s.Fired, pixel{Date: p.Date, Quantity: p.Quantity})
+			}
+		}
+	}
+	return simulations, nil
+}
+
+func matchNotificationCondition(condition string, quantity float64, threshold float64) (bool, error) {
+	switch condition {
+	case ">":
+		return quantity > threshold, nil
+	case "=":
+		return quantity == threshold, nil
+	case "<":
+		return quantity < threshold, nil
+	case "multipleOf":
+		if threshold == 0 {
+			return false, nil
+		}
+		r := math.Abs(math.Remainder(quantity, threshold))
+		return r < 1e-9, nil
+	}
+	return false, fmt.Errorf("condition `%s` is not supported", condition)
+}
+
+func printNotificationSimulations(out io.Writer, simulations []*notificationSimulation) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "date\tnotification\tcondition\tquantity\tchannel")
+	for _, s := range simulations {
+		condition := fmt.Sprintf("%s %s %s", s.Target, s.Condition, s.Threshold)
+		if s.Skipped != "" {
+			fmt.Fprintf(w, "-\t%s\t%s\tskipped: %s\t%s\n", s.ID, condition, s.Skipped, s.ChannelID)
+			continue
+		}
+		for _, p := range s.Fired {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Date, s.ID, condition, p.Quantity, s.ChannelID)
+		}
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	for _, s := range simulations {
+		if s.Skipped == "" {
+			fmt.Fprintf(out, "%s (%s) would have fired %d times\n", s.ID, s.Name, len(s.Fired))
+		}
+	}
+}
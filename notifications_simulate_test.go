@@ -0,0 +1,120 @@
+package pi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var simulateNotificationsTests = []struct {
+	name     string
+	input    []string
+	exitCode int
+}{
+	{
+		name:     "simulate notifications - not specify graph-id",
+		input:    []string{"ntf", "simulate", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "simulate notifications - invalid format",
+		input:    []string{"ntf", "simulate", "--username", "c-know", "--graph-id", "test-graph", "--format", "csv"},
+		exitCode: 1,
+	},
+}
+
+func TestSimulateNotificationsCommand(t *testing.T) {
+	for _, tt := range simulateNotificationsTests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestSimulateNotifications(t *testing.T) {
+	rules := []notificationRule{
+		{ID: "over", Name: "over five", Target: "quantity", Condition: ">", Threshold: "5", ChannelID: "slack"},
+		{ID: "equal", Name: "exactly three", Target: "quantity", Condition: "=", Threshold: "3", ChannelID: "slack"},
+		{ID: "under", Name: "under two", Target: "quantity", Condition: "<", Threshold: "2", ChannelID: "other"},
+		{ID: "multiple", Name: "every 2.5", Target: "quantity", Condition: "multipleOf", Threshold: "2.5", ChannelID: "slack"},
+		{ID: "unknown", Name: "unknown condition", Target: "quantity", Condition: ">=", Threshold: "1", ChannelID: "slack"},
+		{ID: "reminder", Name: "reminder", Target: "remindBy", Condition: ">", Threshold: "23", ChannelID: "slack"},
+	}
+	pixels := []pixel{
+		{Date: "20190101", Quantity: "1"},
+		{Date: "20190102", Quantity: "3"},
+		{Date: "20190103", Quantity: "7.5"},
+		{Date: "20190104", Quantity: "5"},
+	}
+
+	got, err := simulateNotifications(rules, pixels)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	want := map[string]string{
+		"over":     "20190103",
+		"equal":    "20190102",
+		"under":    "20190101",
+		"multiple": "20190103,20190104",
+		"unknown":  "",
+		"reminder": "",
+	}
+	for _, s := range got {
+		dates := []string{}
+		for _, p := range s.Fired {
+			dates = append(dates, p.Date)
+		}
+		if strings.Join(dates, ",") != want[s.ID] {
+			t.Errorf("%s: fired on %v", s.ID, dates)
+		}
+	}
+	if got[4].Skipped == "" || got[5].Skipped == "" {
+		t.Errorf("unsupported rules should be skipped. %q %q", got[4].Skipped, got[5].Skipped)
+	}
+
+	_, err = simulateNotifications(rules, []pixel{{Date: "20190101", Quantity: "x"}})
+	if err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+
+	out := &bytes.Buffer{}
+	printNotificationSimulations(out, got)
+	lines := strings.Split(out.String(), "\n")
+	if strings.Join(strings.Fields(lines[1]), " ") != "20190103 over quantity > 5 7.5 slack" {
+		t.Errorf("Unexpected row. %s", lines[1])
+	}
+	if !strings.Contains(out.String(), "multiple (every 2.5) would have fired 2 times") {
+		t.Errorf("Output does not contain the summary.\n%s", out.String())
+	}
+}
+
+func TestSimulateNotificationsOverYears(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.addGraph("c-know", graphDefinition{ID: "test-id", Type: "int"})
+	pixela.rules["c-know/test-id"] = []notificationRule{{ID: "high", Target: "quantity", Condition: ">", Threshold: "5"}}
+	pixela.setPixel("c-know/test-id", "20180105", pixelBody{Quantity: "10"})
+	pixela.setPixel("c-know/test-id", "20191230", pixelBody{Quantity: "10"})
+
+	exitCode := (&CLI{
+		ErrStream: ioutil.Discard,
+		OutStream: ioutil.Discard,
+	}).Run([]string{"ntf", "simulate", "-g", "test-id", "--from", "20180101", "--to", "20191231"})
+	if exitCode != 0 {
+		t.Fatalf("Unexpected exit code. %d", exitCode)
+	}
+	fetched := 0
+	for _, r := range pixela.requested() {
+		if strings.HasSuffix(r, "/pixels") {
+			fetched++
+		}
+	}
+	if fetched != 2 {
+		t.Errorf("the period over a year should be fetched by year. %v", pixela.requested())
+	}
+}
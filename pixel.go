@@ -1,8 +1,15 @@
 package pi
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 )
 
 type pixelCommand struct {
@@ -12,11 +19,16 @@ type pixelCommand struct {
 	Increment incrementPixelCommand `description:"increment a Pixel" command:"increment" subcommands-optional:"true"`
 	Decrement decrementPixelCommand `description:"decrement a Pixel" command:"decrement" subcommands-optional:"true"`
 	Delete    deletePixelCommand    `description:"delete a Pixel" command:"delete" subcommands-optional:"true"`
+	Add       addPixelCommand       `description:"add quantity to a Pixel" command:"add" subcommands-optional:"true"`
+	Subtract  subtractPixelCommand  `description:"subtract quantity from a Pixel" command:"subtract" subcommands-optional:"true"`
+	Latest    getLatestPixelCommand `description:"get the latest Pixel" command:"latest" subcommands-optional:"true"`
+	Today     getTodayPixelCommand  `description:"get today's Pixel" command:"today" subcommands-optional:"true"`
+	Batch     batchPixelsCommand    `description:"post multiple Pixels at once" command:"batch" subcommands-optional:"true"`
 }
 
 type postPixelCommand struct {
 	Username     string `short:"u" long:"username" description:"User name of graph owner."`
-	ID           string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	ID           string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph. Multiple graphs can be specified by comma separated IDs, a glob (team-*) or a regular expression (/^team-/)." required:"true"`
 	Date         string `short:"d" long:"date" description:"The date on which the quantity is to be recorded. It is specified in yyyyMMdd format." required:"true"`
 	Quantity     string `short:"q" long:"quantity" description:"Specify the quantity to be registered on the specified date." required:"true"`
 	OptionalData string `short:"o" long:"optional-data" description:"Additional information other than quantity. It is specified as JSON string."`
@@ -47,7 +59,7 @@ type updatePixelParam struct {
 
 type incrementPixelCommand struct {
 	Username string `short:"u" long:"username" description:"User name of graph owner."`
-	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph. Multiple graphs can be specified by comma separated IDs, a glob (team-*) or a regular expression (/^team-/)." required:"true"`
 }
 
 type decrementPixelCommand struct {
@@ -61,7 +73,63 @@ type deletePixelCommand struct {
 	Date     string `short:"d" long:"date" description:"The date on which the quantity is to be recorded. It is specified in yyyyMMdd format." required:"true"`
 }
 
+type addPixelCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	Quantity string `short:"q" long:"quantity" description:"Specify the quantity to be added." required:"true"`
+	Date     string `short:"d" long:"date" description:"The date of the pixel in yyyyMMdd format. Defaults to today."`
+}
+type addPixelParam struct {
+	Quantity string `json:"quantity"`
+}
+
+type subtractPixelCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	Quantity string `short:"q" long:"quantity" description:"Specify the quantity to be subtracted." required:"true"`
+	Date     string `short:"d" long:"date" description:"The date of the pixel in yyyyMMdd format. Defaults to today."`
+}
+type subtractPixelParam struct {
+	Quantity string `json:"quantity"`
+}
+
+type getLatestPixelCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+}
+
+type getTodayPixelCommand struct {
+	Username    string `short:"u" long:"username" description:"User name of graph owner."`
+	ID          string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	ReturnEmpty bool   `short:"e" long:"return-empty" description:"When today's Pixel is not registered, return a Pixel of quantity 0 instead of an error."`
+}
+
+type batchPixelsCommand struct {
+	Username  string `short:"u" long:"username" description:"User name of graph owner."`
+	ID        string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	File      string `short:"f" long:"file" description:"JSON file containing an array of objects with date, quantity and optionalData. Specify - to read from stdin. Posting them at once is a limited feature, and they are posted one by one if it is refused. For detail, see https://github.com/a-know/Pixela/wiki/How-to-support-Pixela-by-Patreon-%EF%BC%8F-Use-Limited-Features" required:"true"`
+	ChunkSize int    `long:"chunk-size" description:"The number of Pixels posted in one request." default:"100"`
+}
+
+// batchPixel accepts quantity as a number as well as a string, and optionalData as an object as well as a JSON string.
+type batchPixel struct {
+	Date         string          `json:"date"`
+	Quantity     json.Number     `json:"quantity"`
+	OptionalData json.RawMessage `json:"optionalData"`
+}
+
+// pixelUpdateRetries is the number of attempts to update a pixel by get and update when it is modified concurrently.
+const pixelUpdateRetries = 3
+
 func (pP *postPixelCommand) Execute(args []string) error {
+	if isMultiGraph(pP.ID) {
+		return doMultiGraphRequests(pP.Username, pP.ID, func(id string) (*http.Request, error) {
+			cmd := *pP
+			cmd.ID = id
+			return generatePostPixelRequest(&cmd)
+		})
+	}
+
 	req, err := generatePostPixelRequest(pP)
 	if err != nil {
 		return err
@@ -157,6 +225,14 @@ func generateUpdatePixelRequest(uP *updatePixelCommand) (*http.Request, error) {
 }
 
 func (iP *incrementPixelCommand) Execute(args []string) error {
+	if isMultiGraph(iP.ID) {
+		return doMultiGraphRequests(iP.Username, iP.ID, func(id string) (*http.Request, error) {
+			cmd := *iP
+			cmd.ID = id
+			return generateIncrementPixelRequest(&cmd)
+		})
+	}
+
 	req, err := generateIncrementPixelRequest(iP)
 	if err != nil {
 		return err
@@ -239,3 +315,418 @@ func generateDeletePixelRequest(dP *deletePixelCommand) (*http.Request, error) {
 
 	return req, nil
 }
+
+func (aP *addPixelCommand) Execute(args []string) error {
+	var b []byte
+	if aP.Date == "" {
+		req, err := generateAddPixelRequest(aP)
+		if err != nil {
+			return err
+		}
+		b, err = changePixel(req, aP.Username, aP.ID, aP.Quantity, 1)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		b, err = changePixelQuantity(aP.Username, aP.ID, aP.Date, aP.Quantity, 1)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func generateAddPixelRequest(aP *addPixelCommand) (*http.Request, error) {
+	username, err := getUsername(aP.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	paramStruct := &addPixelParam{
+		Quantity: aP.Quantity,
+	}
+
+	req, err := generateRequestWithToken(
+		"PUT",
+		fmt.Sprintf("v1/users/%s/graphs/%s/add", username, aP.ID),
+		paramStruct,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate add api request : %s", err)
+	}
+
+	return req, nil
+}
+
+func (sP *subtractPixelCommand) Execute(args []string) error {
+	var b []byte
+	if sP.Date == "" {
+		req, err := generateSubtractPixelRequest(sP)
+		if err != nil {
+			return err
+		}
+		b, err = changePixel(req, sP.Username, sP.ID, sP.Quantity, -1)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		b, err = changePixelQuantity(sP.Username, sP.ID, sP.Date, sP.Quantity, -1)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func generateSubtractPixelRequest(sP *subtractPixelCommand) (*http.Request, error) {
+	username, err := getUsername(sP.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	paramStruct := &subtractPixelParam{
+		Quantity: sP.Quantity,
+	}
+
+	req, err := generateRequestWithToken(
+		"PUT",
+		fmt.Sprintf("v1/users/%s/graphs/%s/subtract", username, sP.ID),
+		paramStruct,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate subtract api request : %s", err)
+	}
+
+	return req, nil
+}
+
+// changePixel sends the add or subtract request, and falls back to get and update of today's pixel
+// if the server does not provide those APIs. It returns the response, which is the one of the update by the fallback.
+func changePixel(req *http.Request, cmdUsername string, id string, quantity string, sign float64) ([]byte, error) {
+	b, err := doRequestAndGetBody(req)
+	if isNotFound(err) {
+		return changePixelQuantity(cmdUsername, id, "", quantity, sign)
+	}
+	return b, err
+}
+
+// changePixelQuantity adds `quantity` multiplied by `sign` to the pixel of the date, and returns the response of the update.
+// If the date is empty, today in the graph's timezone is used.
+func changePixelQuantity(cmdUsername string, id string, date string, quantity string, sign float64) ([]byte, error) {
+	username, err := getUsername(cmdUsername)
+	if err != nil {
+		return nil, err
+	}
+	delta, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quantity `%s`", quantity)
+	}
+
+	def, err := fetchGraphDefinition(username, id)
+	if err != nil {
+		return nil, err
+	}
+	if date == "" {
+		today, err := def.today()
+		if err != nil {
+			return nil, err
+		}
+		date = today.Format(pixelDateLayout)
+	}
+
+	_, b, err := addPixelQuantity(username, def, date, delta*sign)
+	return b, err
+}
+
+func (gL *getLatestPixelCommand) Execute(args []string) error {
+	req, err := generateGetLatestPixelRequest(gL)
+	if err != nil {
+		return err
+	}
+
+	err = doRequest(req)
+	return err
+}
+
+func generateGetLatestPixelRequest(gL *getLatestPixelCommand) (*http.Request, error) {
+	username, err := getUsername(gL.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := generateRequestWithToken(
+		"GET",
+		fmt.Sprintf("v1/users/%s/graphs/%s/latest", username, gL.ID),
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate get api request : %s", err)
+	}
+
+	return req, nil
+}
+
+func (gT *getTodayPixelCommand) Execute(args []string) error {
+	req, err := generateGetTodayPixelRequest(gT)
+	if err != nil {
+		return err
+	}
+
+	err = doRequest(req)
+	return err
+}
+
+func generateGetTodayPixelRequest(gT *getTodayPixelCommand) (*http.Request, error) {
+	username, err := getUsername(gT.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("v1/users/%s/graphs/%s/today", username, gT.ID)
+	if gT.ReturnEmpty {
+		url = fmt.Sprintf("%s?returnEmpty=true", url)
+	}
+
+	req, err := generateRequestWithToken(
+		"GET",
+		url,
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate get api request : %s", err)
+	}
+
+	return req, nil
+}
+
+func (bP *batchPixelsCommand) Execute(args []string) error {
+	var b []byte
+	var err error
+	if bP.File == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(bP.File)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to read pixels : %s", err)
+	}
+
+	pixels, err := parseBatchPixels(b)
+	if err != nil {
+		return err
+	}
+	return postPixelsInBatches(bP, pixels)
+}
+
+func parseBatchPixels(b []byte) ([]postPixelParam, error) {
+	var input []batchPixel
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	err := decoder.Decode(&input)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse pixels : %s", err)
+	}
+
+	pixels := make([]postPixelParam, 0, len(input))
+	for i, p := range input {
+		if p.Date == "" || p.Quantity == "" {
+			return nil, fmt.Errorf("date and quantity are required (pixel #%d)", i+1)
+		}
+		param := postPixelParam{Date: p.Date, Quantity: p.Quantity.String()}
+
+		optionalData := bytes.TrimSpace(p.OptionalData)
+		if len(optionalData) > 0 && string(optionalData) != "null" {
+			var s string
+			if json.Unmarshal(optionalData, &s) == nil {
+				param.OptionalData = s
+			} else {
+				param.OptionalData = string(optionalData)
+			}
+		}
+		pixels = append(pixels, param)
+	}
+	return pixels, nil
+}
+
+// postPixelsInBatches splits the pixels into chunks so that each request stays within the limit of the server.
+// The batch API is a limited feature, so the pixels are posted one by one once it is refused.
+func postPixelsInBatches(bP *batchPixelsCommand, pixels []postPixelParam) error {
+	if bP.ChunkSize < 1 {
+		return fmt.Errorf("chunk size must be greater than 0")
+	}
+
+	batch := true
+	for start := 0; start < len(pixels); start += bP.ChunkSize {
+		end := start + bP.ChunkSize
+		if end > len(pixels) {
+			end = len(pixels)
+		}
+
+		var err error
+		if batch {
+			err = postBatchPixels(bP, pixels[start:end])
+			if isBatchRefused(err) {
+				log.Printf("warning: batch api is refused, so the pixels are posted one by one : %s", strings.TrimSpace(err.Error()))
+				batch = false
+			}
+		}
+		if !batch {
+			err = postPixelsOneByOne(bP, pixels[start:end])
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to post pixels from %s to %s : %s", pixels[start].Date, pixels[end-1].Date, err)
+		}
+		fmt.Printf("posted %d/%d pixels\n", end, len(pixels))
+	}
+	return nil
+}
+
+func postBatchPixels(bP *batchPixelsCommand, pixels []postPixelParam) error {
+	req, err := generateBatchPixelsRequest(bP, pixels)
+	if err != nil {
+		return err
+	}
+	_, err = doRequestAndGetBody(req)
+	return err
+}
+
+func postPixelsOneByOne(bP *batchPixelsCommand, pixels []postPixelParam) error {
+	for _, p := range pixels {
+		req, err := generatePostPixelRequest(&postPixelCommand{
+			Username:     bP.Username,
+			ID:           bP.ID,
+			Date:         p.Date,
+			Quantity:     p.Quantity,
+			OptionalData: p.OptionalData,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = doRequestAndGetBody(req)
+		if err != nil {
+			return fmt.Errorf("%s : %s", p.Date, err)
+		}
+	}
+	return nil
+}
+
+// isBatchRefused reports whether the batch API is refused for the user, rather than the request failed.
+// Invalid pixels are also refused, and they are reported by the posts one by one.
+func isBatchRefused(err error) bool {
+	apiErr, ok := err.(*apiError)
+	if !ok {
+		return false
+	}
+	return apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 && apiErr.StatusCode != http.StatusTooManyRequests
+}
+
+func generateBatchPixelsRequest(bP *batchPixelsCommand, pixels []postPixelParam) (*http.Request, error) {
+	username, err := getUsername(bP.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := generateRequestWithToken(
+		"POST",
+		fmt.Sprintf("v1/users/%s/graphs/%s/pixels", username, bP.ID),
+		pixels,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate batch api request : %s", err)
+	}
+
+	return req, nil
+}
+
+type pixelBody struct {
+	Quantity     string `json:"quantity"`
+	OptionalData string `json:"optionalData,omitempty"`
+}
+
+// fetchPixel returns nil if the pixel is not registered.
+func fetchPixel(username string, id string, date string) (*pixelBody, error) {
+	req, err := generateGetPixelRequest(&getPixelCommand{
+		Username: username,
+		ID:       id,
+		Date:     date,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the pixel is read to be modified, so it must be the latest.
+	req.Header.Set("Cache-Control", "no-cache")
+
+	b, err := doRequestAndGetBody(req)
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pixelBody{}
+	err = json.Unmarshal(b, p)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse pixel : %s", err)
+	}
+	return p, nil
+}
+
+// addPixelQuantity adds `delta` to the pixel of the date by get and update, keeping its optionalData.
+// The pixel is read again just before the update, and the update is retried if it has been modified meanwhile.
+// The API has no conditional update, so a change between the second read and the update is still lost.
+// It returns the updated quantity and the response of the update.
+func addPixelQuantity(username string, def *graphDefinition, date string, delta float64) (string, []byte, error) {
+	for i := 0; i < pixelUpdateRetries; i++ {
+		current, err := fetchPixel(username, def.ID, date)
+		if err != nil {
+			return "", nil, err
+		}
+
+		cmd := &updatePixelCommand{
+			Username: username,
+			ID:       def.ID,
+			Date:     date,
+		}
+		quantity := delta
+		if current != nil {
+			q, err := strconv.ParseFloat(current.Quantity, 64)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid quantity `%s` on %s", current.Quantity, date)
+			}
+			quantity += q
+			cmd.OptionalData = current.OptionalData
+		}
+		cmd.Quantity = def.formatQuantity(quantity)
+
+		latest, err := fetchPixel(username, def.ID, date)
+		if err != nil {
+			return "", nil, err
+		}
+		if !samePixel(current, latest) {
+			continue
+		}
+
+		req, err := generateUpdatePixelRequest(cmd)
+		if err != nil {
+			return "", nil, err
+		}
+		b, err := doRequestAndGetBody(req)
+		if err != nil {
+			return "", nil, err
+		}
+		return cmd.Quantity, b, nil
+	}
+	return "", nil, fmt.Errorf("the pixel of %s is being modified by others. please try again later", date)
+}
+
+func samePixel(a *pixelBody, b *pixelBody) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
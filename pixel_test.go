@@ -3,6 +3,8 @@ package pi
 import (
 	"fmt"
 	"io/ioutil"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -101,6 +103,76 @@ var pixelTests = []struct {
 		input:    []string{"pixel", "delete", "--graph-id", "test-id", "--date", "20190101"},
 		exitCode: 1,
 	},
+	{
+		name:     "add pixel - not specify id",
+		input:    []string{"pixel", "add", "--username", "c-know", "--quantity", "2.5"},
+		exitCode: 1,
+	},
+	{
+		name:     "add pixel - not specify quantity",
+		input:    []string{"pixel", "add", "--username", "c-know", "--graph-id", "test-id"},
+		exitCode: 1,
+	},
+	{
+		name:     "add pixel - not specify username",
+		input:    []string{"pixel", "add", "--graph-id", "test-id", "--quantity", "2.5"},
+		exitCode: 1,
+	},
+	{
+		name:     "add pixel - invalid quantity with date",
+		input:    []string{"pixel", "add", "--username", "c-know", "--graph-id", "test-id", "--quantity", "many", "--date", "20190101"},
+		exitCode: 1,
+	},
+	{
+		name:     "subtract pixel - not specify id",
+		input:    []string{"pixel", "subtract", "--username", "c-know", "--quantity", "2.5"},
+		exitCode: 1,
+	},
+	{
+		name:     "subtract pixel - not specify quantity",
+		input:    []string{"pixel", "subtract", "--username", "c-know", "--graph-id", "test-id"},
+		exitCode: 1,
+	},
+	{
+		name:     "subtract pixel - not specify username",
+		input:    []string{"pixel", "subtract", "--graph-id", "test-id", "--quantity", "2.5"},
+		exitCode: 1,
+	},
+	{
+		name:     "get latest pixel - not specify id",
+		input:    []string{"pixel", "latest", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "get latest pixel - not specify username",
+		input:    []string{"pixel", "latest", "--graph-id", "test-id"},
+		exitCode: 1,
+	},
+	{
+		name:     "get today pixel - not specify id",
+		input:    []string{"pixel", "today", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "get today pixel - not specify username",
+		input:    []string{"pixel", "today", "--graph-id", "test-id"},
+		exitCode: 1,
+	},
+	{
+		name:     "batch pixels - not specify id",
+		input:    []string{"pixel", "batch", "--username", "c-know", "--file", "pixels.json"},
+		exitCode: 1,
+	},
+	{
+		name:     "batch pixels - not specify file",
+		input:    []string{"pixel", "batch", "--username", "c-know", "--graph-id", "test-id"},
+		exitCode: 1,
+	},
+	{
+		name:     "batch pixels - file not exist",
+		input:    []string{"pixel", "batch", "--username", "c-know", "--graph-id", "test-id", "--file", "not-exist.json"},
+		exitCode: 1,
+	},
 }
 
 func TestPixel(t *testing.T) {
@@ -401,3 +473,504 @@ func TestGenerateDeletePixelRequest(t *testing.T) {
 		t.Errorf("Unexpected request body. %s", string(b))
 	}
 }
+
+func TestGenerateAddPixelRequest(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+
+	testUsername := "c-know"
+	testID := "test-id"
+	testQuantity := "2.5"
+	cmd := &addPixelCommand{
+		Username: testUsername,
+		ID:       testID,
+		Quantity: testQuantity,
+	}
+
+	// run
+	req, err := generateAddPixelRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "PUT" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/v1/users/%s/graphs/%s/add", afterAPIBaseEnv, testUsername, testID) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		t.Errorf("Failed to read request body. %s", err)
+	}
+	if string(b) != fmt.Sprintf(`{"quantity":"%s"}`, testQuantity) {
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}
+
+func TestGenerateSubtractPixelRequest(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+
+	testUsername := "c-know"
+	testID := "test-id"
+	testQuantity := "2.5"
+	cmd := &subtractPixelCommand{
+		Username: testUsername,
+		ID:       testID,
+		Quantity: testQuantity,
+	}
+
+	// run
+	req, err := generateSubtractPixelRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "PUT" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/v1/users/%s/graphs/%s/subtract", afterAPIBaseEnv, testUsername, testID) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		t.Errorf("Failed to read request body. %s", err)
+	}
+	if string(b) != fmt.Sprintf(`{"quantity":"%s"}`, testQuantity) {
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}
+
+func TestSamePixel(t *testing.T) {
+	tests := []struct {
+		a, b *pixelBody
+		want bool
+	}{
+		{a: nil, b: nil, want: true},
+		{a: &pixelBody{Quantity: "1"}, b: nil, want: false},
+		{a: nil, b: &pixelBody{Quantity: "1"}, want: false},
+		{a: &pixelBody{Quantity: "1"}, b: &pixelBody{Quantity: "1"}, want: true},
+		{a: &pixelBody{Quantity: "1"}, b: &pixelBody{Quantity: "2"}, want: false},
+		{a: &pixelBody{Quantity: "1", OptionalData: "a"}, b: &pixelBody{Quantity: "1"}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := samePixel(tt.a, tt.b); got != tt.want {
+			t.Errorf("Unexpected result for %v and %v. got=%t want=%t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFetchPixel(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.addGraph("c-know", graphDefinition{ID: "test-id", Type: "int"})
+	pixela.setPixel("c-know/test-id", "20201019", pixelBody{Quantity: "5", OptionalData: `{"a":1}`})
+
+	p, err := fetchPixel("c-know", "test-id", "20201019")
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if p == nil || *p != (pixelBody{Quantity: "5", OptionalData: `{"a":1}`}) {
+		t.Errorf("Unexpected pixel. %v", p)
+	}
+
+	p, err = fetchPixel("c-know", "test-id", "20201020")
+	if err != nil || p != nil {
+		t.Errorf("Unregistered pixel must be nil. %v %v", p, err)
+	}
+
+	if _, err := fetchPixel("a-know", "test-id", "20201019"); err == nil {
+		t.Errorf("Expected error for the graph of other user, but not occurred.")
+	}
+}
+
+func TestChangePixelQuantity(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.addGraph("c-know", graphDefinition{ID: "int-id", Type: "int"})
+	pixela.addGraph("c-know", graphDefinition{ID: "float-id", Type: "float"})
+	pixela.setPixel("c-know/int-id", "20201019", pixelBody{Quantity: "5", OptionalData: `{"a":1}`})
+
+	tests := []struct {
+		id       string
+		date     string
+		quantity string
+		sign     float64
+		want     pixelBody
+	}{
+		{"int-id", "20201019", "3", 1, pixelBody{Quantity: "8", OptionalData: `{"a":1}`}},
+		{"int-id", "20201019", "10", -1, pixelBody{Quantity: "-2", OptionalData: `{"a":1}`}},
+		{"int-id", "20201020", "2", 1, pixelBody{Quantity: "2"}},
+		{"float-id", "20201019", "0.1", 1, pixelBody{Quantity: "0.1"}},
+		{"float-id", "20201019", "0.2", 1, pixelBody{Quantity: "0.3"}},
+	}
+	for _, tt := range tests {
+		_, err := changePixelQuantity("c-know", tt.id, tt.date, tt.quantity, tt.sign)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error occurs. %s", tt.id, tt.date, err)
+			continue
+		}
+		if got, _ := pixela.pixel("c-know/"+tt.id, tt.date); got != tt.want {
+			t.Errorf("%s %s: pixel=%v want=%v", tt.id, tt.date, got, tt.want)
+		}
+	}
+
+	if _, err := changePixelQuantity("c-know", "int-id", "20201019", "x", 1); err == nil {
+		t.Errorf("Expected error for invalid quantity, but not occurred.")
+	}
+	if _, err := changePixelQuantity("c-know", "unknown-id", "20201019", "1", 1); err == nil {
+		t.Errorf("Expected error for unknown graph, but not occurred.")
+	}
+}
+
+func TestAddPixelQuantityConflict(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	def := graphDefinition{ID: "test-id", Type: "int"}
+	pixela.addGraph("c-know", def)
+	pixela.setPixel("c-know/test-id", "20201019", pixelBody{Quantity: "5"})
+
+	// others add 1 once while the pixel is read, and the update is retried with their change.
+	reads := 0
+	pixela.onGetPixel = func(key string, date string) {
+		reads++
+		if reads == 1 {
+			pixela.pixels[key][date] = pixelBody{Quantity: "6"}
+		}
+	}
+	total, _, err := addPixelQuantity("c-know", &def, "20201019", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if got, _ := pixela.pixel("c-know/test-id", "20201019"); total != "8" || got.Quantity != "8" {
+		t.Errorf("Unexpected quantity. total=%s pixel=%s", total, got.Quantity)
+	}
+
+	// the pixel keeps changing.
+	pixela.onGetPixel = func(key string, date string) {
+		q, _ := strconv.Atoi(pixela.pixels[key][date].Quantity)
+		pixela.pixels[key][date] = pixelBody{Quantity: strconv.Itoa(q + 1)}
+	}
+	if _, _, err := addPixelQuantity("c-know", &def, "20201019", 2); err == nil {
+		t.Errorf("Expected error for the pixel being modified, but not occurred.")
+	}
+	for _, r := range pixela.requested() {
+		if strings.HasPrefix(r, "PUT ") && r != "PUT /v1/users/c-know/graphs/test-id/20201019" {
+			t.Errorf("Unexpected update. %s", r)
+		}
+	}
+}
+
+func TestChangePixel(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	def := graphDefinition{ID: "test-id", Type: "int"}
+	pixela.addGraph("c-know", def)
+	today, _ := def.today()
+	date := today.Format(pixelDateLayout)
+
+	req, err := generateAddPixelRequest(&addPixelCommand{ID: "test-id", Quantity: "3"})
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if _, err := changePixel(req, "", "test-id", "3", 1); err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if got, _ := pixela.pixel("c-know/test-id", date); got.Quantity != "3" {
+		t.Errorf("Unexpected quantity by add api. %s", got.Quantity)
+	}
+
+	// the server without add and subtract api.
+	pixela.noAdd = true
+	req, err = generateSubtractPixelRequest(&subtractPixelCommand{ID: "test-id", Quantity: "1"})
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	b, err := changePixel(req, "", "test-id", "1", -1)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if got, _ := pixela.pixel("c-know/test-id", date); got.Quantity != "2" {
+		t.Errorf("Unexpected quantity by fallback. %s", got.Quantity)
+	}
+	if !strings.Contains(string(b), `"isSuccess":true`) {
+		t.Errorf("Unexpected response of fallback. %s", b)
+	}
+	want := []string{
+		"PUT /v1/users/c-know/graphs/test-id/add",
+		"PUT /v1/users/c-know/graphs/test-id/subtract",
+		"GET /v1/users/c-know/graphs/test-id/graph-def",
+		"GET /v1/users/c-know/graphs/test-id/" + date,
+		"GET /v1/users/c-know/graphs/test-id/" + date,
+		"PUT /v1/users/c-know/graphs/test-id/" + date,
+	}
+	if got := pixela.requested(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected requests.\n%s", strings.Join(got, "\n"))
+	}
+
+	// other errors are not fallen back.
+	req, err = generateAddPixelRequest(&addPixelCommand{ID: "test-id", Quantity: "1"})
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	req.Header.Set("X-USER-TOKEN", "wrong")
+	if _, err := changePixel(req, "", "test-id", "1", 1); err == nil {
+		t.Errorf("Expected error for wrong token, but not occurred.")
+	}
+}
+
+func TestGenerateGetLatestPixelRequest(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+
+	testUsername := "c-know"
+	testID := "test-id"
+	cmd := &getLatestPixelCommand{
+		Username: testUsername,
+		ID:       testID,
+	}
+
+	// run
+	req, err := generateGetLatestPixelRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "GET" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/v1/users/%s/graphs/%s/latest", afterAPIBaseEnv, testUsername, testID) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		defer req.Body.Close()
+		if err != nil {
+			t.Errorf("Failed to read request body. %s", err)
+		}
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}
+
+func TestGenerateGetTodayPixelRequest(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+
+	testUsername := "c-know"
+	testID := "test-id"
+	cmd := &getTodayPixelCommand{
+		Username: testUsername,
+		ID:       testID,
+	}
+
+	// run
+	req, err := generateGetTodayPixelRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "GET" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/v1/users/%s/graphs/%s/today", afterAPIBaseEnv, testUsername, testID) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		defer req.Body.Close()
+		if err != nil {
+			t.Errorf("Failed to read request body. %s", err)
+		}
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}
+
+func TestGenerateGetTodayPixelRequestReturnEmpty(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+
+	testUsername := "c-know"
+	testID := "test-id"
+	cmd := &getTodayPixelCommand{
+		Username:    testUsername,
+		ID:          testID,
+		ReturnEmpty: true,
+	}
+
+	// run
+	req, err := generateGetTodayPixelRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "GET" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/v1/users/%s/graphs/%s/today?returnEmpty=true", afterAPIBaseEnv, testUsername, testID) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		defer req.Body.Close()
+		if err != nil {
+			t.Errorf("Failed to read request body. %s", err)
+		}
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}
+
+func TestParseBatchPixels(t *testing.T) {
+	input := `[
+		{"date": "20190101", "quantity": 5},
+		{"date": "20190102", "quantity": "1.5", "optionalData": "{\"key\":\"value\"}"},
+		{"date": "20190103", "quantity": 2, "optionalData": {"key": "value"}}
+	]`
+
+	pixels, err := parseBatchPixels([]byte(input))
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	want := []postPixelParam{
+		{Date: "20190101", Quantity: "5"},
+		{Date: "20190102", Quantity: "1.5", OptionalData: `{"key":"value"}`},
+		{Date: "20190103", Quantity: "2", OptionalData: `{"key": "value"}`},
+	}
+	if len(pixels) != len(want) {
+		t.Fatalf("Unexpected pixels. %v", pixels)
+	}
+	for i := range want {
+		if pixels[i] != want[i] {
+			t.Errorf("Unexpected pixel. got=%v want=%v", pixels[i], want[i])
+		}
+	}
+
+	for _, invalid := range []string{`{"date": "20190101"}`, `[{"date": "20190101"}]`, `[{"quantity": 1}]`} {
+		if _, err := parseBatchPixels([]byte(invalid)); err == nil {
+			t.Errorf("expected error for %s but not occurred", invalid)
+		}
+	}
+}
+
+func TestGenerateBatchPixelsRequest(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, _ := prepare()
+
+	testUsername := "c-know"
+	testID := "test-id"
+	cmd := &batchPixelsCommand{
+		Username: testUsername,
+		ID:       testID,
+	}
+	pixels := []postPixelParam{
+		{Date: "20190101", Quantity: "5"},
+		{Date: "20190102", Quantity: "3", OptionalData: `{"key":"value"}`},
+	}
+
+	// run
+	req, err := generateBatchPixelsRequest(cmd, pixels)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "POST" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/v1/users/%s/graphs/%s/pixels", afterAPIBaseEnv, testUsername, testID) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		t.Errorf("Failed to read request body. %s", err)
+	}
+	if string(b) != `[{"date":"20190101","quantity":"5"},{"date":"20190102","quantity":"3","optionalData":"{\"key\":\"value\"}"}]` {
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}
+
+func TestPostPixelsInBatchesInvalidChunkSize(t *testing.T) {
+	err := postPixelsInBatches(&batchPixelsCommand{Username: "c-know", ID: "test-id"}, []postPixelParam{{Date: "20190101", Quantity: "1"}})
+	if err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
+
+func TestPostPixelsInBatches(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.addGraph("c-know", graphDefinition{ID: "test-id", Type: "int"})
+	pixels := []postPixelParam{
+		{Date: "20190101", Quantity: "1"},
+		{Date: "20190102", Quantity: "2", OptionalData: `{"a":1}`},
+		{Date: "20190103", Quantity: "3"},
+	}
+
+	err := postPixelsInBatches(&batchPixelsCommand{Username: "c-know", ID: "test-id", ChunkSize: 2}, pixels)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	want := []string{
+		"POST /v1/users/c-know/graphs/test-id/pixels",
+		"POST /v1/users/c-know/graphs/test-id/pixels",
+	}
+	if got := pixela.requested(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected requests.\n%s", strings.Join(got, "\n"))
+	}
+
+	// the batch api is refused for the user who can not use limited features.
+	pixela.addGraph("c-know", graphDefinition{ID: "test-id", Type: "int"})
+	pixela.requests = nil
+	pixela.refuseBatch = true
+	err = postPixelsInBatches(&batchPixelsCommand{Username: "c-know", ID: "test-id", ChunkSize: 2}, pixels)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	want = []string{
+		"POST /v1/users/c-know/graphs/test-id/pixels",
+		"POST /v1/users/c-know/graphs/test-id",
+		"POST /v1/users/c-know/graphs/test-id",
+		"POST /v1/users/c-know/graphs/test-id",
+	}
+	if got := pixela.requested(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected requests.\n%s", strings.Join(got, "\n"))
+	}
+	for _, p := range pixels {
+		if got, _ := pixela.pixel("c-know/test-id", p.Date); got != (pixelBody{Quantity: p.Quantity, OptionalData: p.OptionalData}) {
+			t.Errorf("Unexpected pixel of %s. %v", p.Date, got)
+		}
+	}
+
+	// a failure of the posts one by one is reported.
+	err = postPixelsInBatches(&batchPixelsCommand{Username: "c-know", ID: "unknown-id", ChunkSize: 2}, pixels)
+	if err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
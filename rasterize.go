@@ -0,0 +1,362 @@
+package pi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// svgTransform is an axis aligned affine transform.
+// Pixela graphs only use translate and scale, so the elements with rotation or skew are not rendered.
+type svgTransform struct {
+	sx, sy, tx, ty float64
+}
+
+var identityTransform = svgTransform{sx: 1, sy: 1}
+
+func (t svgTransform) apply(x, y float64) (float64, float64) {
+	return x*t.sx + t.tx, y*t.sy + t.ty
+}
+
+// then returns the transform that applies `t` first and `o` after it.
+func (t svgTransform) then(o svgTransform) svgTransform {
+	return svgTransform{
+		sx: t.sx * o.sx,
+		sy: t.sy * o.sy,
+		tx: t.tx*o.sx + o.tx,
+		ty: t.ty*o.sy + o.ty,
+	}
+}
+
+type svgState struct {
+	transform svgTransform
+	fill      string
+	opacity   float64
+	// hidden is set under an unsupported transform.
+	hidden bool
+}
+
+const (
+	maxRasterizeScale = 10
+	// maxRasterizeSize is the limit of width and height of the image in pixels.
+	maxRasterizeSize = 10000
+)
+
+// svgFallbackColor is used for the colors which are not supported.
+var svgFallbackColor = color.NRGBA{0x80, 0x80, 0x80, 0xff}
+
+var errUnsupportedTransform = fmt.Errorf("unsupported transform")
+
+func validateRasterizeScale(scale float64) error {
+	if math.IsNaN(scale) || scale <= 0 || scale > maxRasterizeScale {
+		return fmt.Errorf("scale must be greater than 0 and at most %d", maxRasterizeScale)
+	}
+	return nil
+}
+
+// validateRasterizeMode refuses the modes whose graph is not drawn by rect elements.
+// The line mode draws the graph with paths, so its PNG would be blank.
+func validateRasterizeMode(mode string) error {
+	if mode == "line" {
+		return fmt.Errorf("the graph of mode `%s` can not be converted to png. download it as svg instead", mode)
+	}
+	return nil
+}
+
+// rasterizeSVG renders the rect elements of a Pixela SVG graph into an image.
+// Text elements are not rendered, so the labels of months and weekdays are missing.
+func rasterizeSVG(src []byte, scale float64) (*image.RGBA, error) {
+	if err := validateRasterizeScale(scale); err != nil {
+		return nil, err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(src))
+	var img *image.RGBA
+	stack := []svgState{}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse svg : %s", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			attrs := svgAttributes(t)
+			parent := svgState{transform: svgTransform{sx: scale, sy: scale}, fill: "black", opacity: 1}
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			state, err := inheritSVGState(parent, attrs)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, state)
+
+			switch t.Name.Local {
+			case "svg":
+				if img != nil {
+					continue
+				}
+				width, height, err := svgSize(attrs)
+				if err != nil {
+					return nil, err
+				}
+				if width*scale > maxRasterizeSize || height*scale > maxRasterizeSize {
+					return nil, fmt.Errorf("the image is too large : %gx%g", width*scale, height*scale)
+				}
+				img = image.NewRGBA(image.Rect(0, 0, int(math.Ceil(width*scale)), int(math.Ceil(height*scale))))
+			case "rect":
+				if img == nil {
+					return nil, fmt.Errorf("Failed to parse svg : rect is found outside of svg element")
+				}
+				if err := drawSVGRect(img, state, attrs); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	if img == nil {
+		return nil, fmt.Errorf("Failed to parse svg : svg element is not found")
+	}
+	return img, nil
+}
+
+func svgAttributes(e xml.StartElement) map[string]string {
+	attrs := map[string]string{}
+	for _, a := range e.Attr {
+		attrs[a.Name.Local] = strings.TrimSpace(a.Value)
+	}
+	// inline style declarations take precedence over presentation attributes.
+	for _, decl := range strings.Split(attrs["style"], ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		attrs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return attrs
+}
+
+func inheritSVGState(parent svgState, attrs map[string]string) (svgState, error) {
+	state := parent
+	if v, ok := attrs["transform"]; ok {
+		t, err := parseSVGTransform(v)
+		if err == errUnsupportedTransform {
+			state.hidden = true
+		} else if err != nil {
+			return state, err
+		}
+		state.transform = t.then(parent.transform)
+	}
+	if v, ok := attrs["fill"]; ok && v != "inherit" {
+		state.fill = v
+	}
+	for _, name := range []string{"opacity", "fill-opacity"} {
+		if v, ok := attrs[name]; ok {
+			o, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return state, fmt.Errorf("Failed to parse svg : invalid %s `%s`", name, v)
+			}
+			state.opacity *= o
+		}
+	}
+	return state, nil
+}
+
+func svgSize(attrs map[string]string) (float64, float64, error) {
+	width, werr := parseSVGLength(attrs["width"])
+	height, herr := parseSVGLength(attrs["height"])
+	if werr == nil && herr == nil {
+		return width, height, nil
+	}
+
+	viewBox := strings.Fields(strings.Replace(attrs["viewBox"], ",", " ", -1))
+	if len(viewBox) == 4 {
+		width, werr = strconv.ParseFloat(viewBox[2], 64)
+		height, herr = strconv.ParseFloat(viewBox[3], 64)
+		if werr == nil && herr == nil {
+			return width, height, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("Failed to parse svg : the size of svg is not specified")
+}
+
+func parseSVGLength(v string) (float64, error) {
+	if v == "" {
+		return 0, fmt.Errorf("empty length")
+	}
+	return strconv.ParseFloat(strings.TrimSuffix(v, "px"), 64)
+}
+
+func parseSVGTransform(v string) (svgTransform, error) {
+	result := identityTransform
+	rest := strings.TrimSpace(v)
+	for rest != "" {
+		open := strings.Index(rest, "(")
+		end := strings.Index(rest, ")")
+		if open < 0 || end < open {
+			return result, fmt.Errorf("Failed to parse svg : invalid transform `%s`", v)
+		}
+		name := strings.TrimSpace(rest[:open])
+		args := []float64{}
+		for _, a := range strings.FieldsFunc(rest[open+1:end], func(r rune) bool { return r == ',' || r == ' ' }) {
+			f, err := strconv.ParseFloat(a, 64)
+			if err != nil {
+				return result, fmt.Errorf("Failed to parse svg : invalid transform `%s`", v)
+			}
+			args = append(args, f)
+		}
+		if len(args) == 0 {
+			return result, fmt.Errorf("Failed to parse svg : invalid transform `%s`", v)
+		}
+
+		var t svgTransform
+		switch name {
+		case "translate":
+			t = svgTransform{sx: 1, sy: 1, tx: args[0]}
+			if len(args) > 1 {
+				t.ty = args[1]
+			}
+		case "scale":
+			t = svgTransform{sx: args[0], sy: args[0]}
+			if len(args) > 1 {
+				t.sy = args[1]
+			}
+		case "matrix":
+			if len(args) != 6 {
+				return result, fmt.Errorf("Failed to parse svg : invalid transform `%s`", v)
+			}
+			if args[1] != 0 || args[2] != 0 {
+				return result, errUnsupportedTransform
+			}
+			t = svgTransform{sx: args[0], sy: args[3], tx: args[4], ty: args[5]}
+		case "rotate", "skewX", "skewY":
+			if args[0] != 0 {
+				return result, errUnsupportedTransform
+			}
+			t = identityTransform
+		default:
+			return result, fmt.Errorf("Failed to parse svg : unknown transform `%s`", name)
+		}
+		// transforms in a list are applied from right to left.
+		result = t.then(result)
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+	return result, nil
+}
+
+func drawSVGRect(img *image.RGBA, state svgState, attrs map[string]string) error {
+	if state.hidden {
+		return nil
+	}
+	values := map[string]float64{}
+	for _, name := range []string{"x", "y", "width", "height"} {
+		if attrs[name] == "" {
+			continue
+		}
+		f, err := parseSVGLength(attrs[name])
+		if err != nil {
+			return fmt.Errorf("Failed to parse svg : invalid rect %s `%s`", name, attrs[name])
+		}
+		values[name] = f
+	}
+
+	c, err := parseSVGColor(state.fill, state.opacity)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return nil
+	}
+
+	x0, y0 := state.transform.apply(values["x"], values["y"])
+	x1, y1 := state.transform.apply(values["x"]+values["width"], values["y"]+values["height"])
+	rect := image.Rect(
+		int(math.Round(x0)), int(math.Round(y0)),
+		int(math.Round(x1)), int(math.Round(y1)),
+	)
+	draw.Draw(img, rect, image.NewUniform(c), image.Point{}, draw.Over)
+	return nil
+}
+
+var svgNamedColors = map[string]color.NRGBA{
+	"black":  {0x00, 0x00, 0x00, 0xff},
+	"white":  {0xff, 0xff, 0xff, 0xff},
+	"gray":   {0x80, 0x80, 0x80, 0xff},
+	"grey":   {0x80, 0x80, 0x80, 0xff},
+	"silver": {0xc0, 0xc0, 0xc0, 0xff},
+	"red":    {0xff, 0x00, 0x00, 0xff},
+	"green":  {0x00, 0x80, 0x00, 0xff},
+	"blue":   {0x00, 0x00, 0xff, 0xff},
+	"yellow": {0xff, 0xff, 0x00, 0xff},
+	"orange": {0xff, 0xa5, 0x00, 0xff},
+	"purple": {0x80, 0x00, 0x80, 0xff},
+}
+
+// parseSVGColor returns nil if the color is `none` or `transparent`.
+func parseSVGColor(v string, opacity float64) (color.Color, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "none" || v == "transparent" {
+		return nil, nil
+	}
+
+	var c color.NRGBA
+	switch {
+	case strings.HasPrefix(v, "#"):
+		hex := v[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return nil, fmt.Errorf("Failed to parse svg : invalid color `%s`", v)
+		}
+		c = color.NRGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 0xff}
+	case strings.HasPrefix(v, "rgb(") || strings.HasPrefix(v, "rgba("):
+		parts := strings.Split(strings.TrimSuffix(v[strings.Index(v, "(")+1:], ")"), ",")
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("Failed to parse svg : invalid color `%s`", v)
+		}
+		rgb := [3]uint8{}
+		for i := 0; i < 3; i++ {
+			n, err := strconv.Atoi(strings.TrimSpace(parts[i]))
+			if err != nil || n < 0 || n > 255 {
+				return nil, fmt.Errorf("Failed to parse svg : invalid color `%s`", v)
+			}
+			rgb[i] = uint8(n)
+		}
+		c = color.NRGBA{rgb[0], rgb[1], rgb[2], 0xff}
+		if len(parts) == 4 {
+			a, err := strconv.ParseFloat(strings.TrimSpace(parts[3]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse svg : invalid color `%s`", v)
+			}
+			opacity *= a
+		}
+	default:
+		named, ok := svgNamedColors[v]
+		if !ok {
+			named = svgFallbackColor
+		}
+		c = named
+	}
+
+	c.A = uint8(math.Round(float64(c.A) * math.Max(0, math.Min(1, opacity))))
+	return c, nil
+}
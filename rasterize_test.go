@@ -0,0 +1,109 @@
+package pi
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestRasterizeSVG(t *testing.T) {
+	svg := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="30" height="20">
+  <rect width="30" height="20" fill="#ffffff"/>
+  <g transform="translate(10, 5)">
+    <rect x="0" y="0" width="5" height="5" fill="#ebedf0"/>
+    <rect x="5" y="5" width="5" height="5" style="fill: rgb(0, 128, 0)"/>
+    <rect x="10" y="0" width="5" height="5" fill="none"/>
+    <text x="0" y="0">Mon</text>
+  </g>
+</svg>`)
+
+	img, err := rasterizeSVG(svg, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if img.Bounds().Dx() != 60 || img.Bounds().Dy() != 40 {
+		t.Errorf("Unexpected image size. %v", img.Bounds())
+	}
+
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{x: 0, y: 0, want: color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{x: 20, y: 10, want: color.RGBA{0xeb, 0xed, 0xf0, 0xff}},
+		{x: 29, y: 19, want: color.RGBA{0xeb, 0xed, 0xf0, 0xff}},
+		{x: 30, y: 20, want: color.RGBA{0x00, 0x80, 0x00, 0xff}},
+		{x: 40, y: 10, want: color.RGBA{0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("Unexpected color at (%d, %d). got=%v want=%v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+func TestRasterizeSVGViewBox(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 4"><rect width="10" height="4" fill="#000" fill-opacity="0.5"/></svg>`)
+
+	img, err := rasterizeSVG(svg, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if img.Bounds().Dx() != 10 || img.Bounds().Dy() != 4 {
+		t.Errorf("Unexpected image size. %v", img.Bounds())
+	}
+	if got := img.RGBAAt(5, 2).A; got != 0x80 {
+		t.Errorf("Unexpected alpha. %d", got)
+	}
+}
+
+func TestRasterizeSVGError(t *testing.T) {
+	tests := []struct {
+		name  string
+		svg   string
+		scale float64
+	}{
+		{name: "invalid scale", svg: `<svg width="1" height="1"></svg>`, scale: 0},
+		{name: "NaN scale", svg: `<svg width="1" height="1"></svg>`, scale: math.NaN()},
+		{name: "no svg element", svg: `<html></html>`, scale: 1},
+		{name: "no size", svg: `<svg></svg>`, scale: 1},
+		{name: "invalid color", svg: `<svg width="1" height="1"><rect width="1" height="1" fill="#zzzzzz"/></svg>`, scale: 1},
+		{name: "too large scale", svg: `<svg width="1" height="1"></svg>`, scale: 11},
+		{name: "too large image", svg: `<svg width="2000" height="10"></svg>`, scale: 10},
+		{name: "unknown transform", svg: `<svg width="1" height="1"><g transform="perspective(1)"></g></svg>`, scale: 1},
+	}
+
+	for _, tt := range tests {
+		if _, err := rasterizeSVG([]byte(tt.svg), tt.scale); err == nil {
+			t.Errorf("%s: expected error but not occurred", tt.name)
+		}
+	}
+}
+
+func TestRasterizeSVGFallback(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="4" height="2">
+  <rect width="1" height="1" fill="papayawhip"/>
+  <g transform="rotate(45)"><rect x="1" width="1" height="1" fill="#000"/></g>
+  <g transform="matrix(2 0 0 2 2 0)"><rect width="1" height="1" fill="#000"/></g>
+  <text x="0" y="2">Mon</text>
+</svg>`)
+
+	img, err := rasterizeSVG(svg, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	tests := []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{x: 0, y: 0, want: color.RGBA{0x80, 0x80, 0x80, 0xff}},
+		{x: 1, y: 0, want: color.RGBA{}},
+		{x: 3, y: 1, want: color.RGBA{0x00, 0x00, 0x00, 0xff}},
+	}
+	for _, tt := range tests {
+		if got := img.RGBAAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("Unexpected color at (%d, %d). got=%v want=%v", tt.x, tt.y, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by the pi processes of the user.
+// The bucket is kept in a file in the cache directory, and it is updated while holding a lock file.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	dir   string
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+type rateLimitState struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+const (
+	rateLimitLockTimeout = 30 * time.Second
+	rateLimitStaleLock   = 10 * time.Second
+	rateLimitLockRetry   = 10 * time.Millisecond
+)
+
+// newRateLimiter reads the budget from `PI_RATE_LIMIT` environment variable or `rateLimit` of the config file.
+// It returns nil if the rate is not limited.
+func newRateLimiter() (*rateLimiter, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	rate := config.RateLimit.RequestsPerSecond
+	if env := os.Getenv("PI_RATE_LIMIT"); env != "" {
+		rate, err = strconv.ParseFloat(env, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate limit `%s`", env)
+		}
+	}
+	if rate == 0 {
+		return nil, nil
+	}
+
+	burst := float64(config.RateLimit.Burst)
+	if burst < 1 {
+		burst = math.Max(1, math.Ceil(rate))
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimiter{rate: rate, burst: burst, dir: dir, now: time.Now, sleep: time.Sleep}, nil
+}
+
+// wait blocks until a request is allowed.
+func (l *rateLimiter) wait() error {
+	d, err := l.reserve()
+	if err != nil {
+		return err
+	}
+	if d > 0 {
+		l.sleep(d)
+	}
+	return nil
+}
+
+// reserve takes a token from the bucket and returns how long to wait for it.
+// The token may be borrowed from the future, so that the waiting processes are served in order.
+func (l *rateLimiter) reserve() (time.Duration, error) {
+	unlock, err := l.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	path := filepath.Join(l.dir, "ratelimit.json")
+	now := l.now()
+	state := &rateLimitState{Tokens: l.burst, Updated: now}
+	if b, err := ioutil.ReadFile(path); err == nil {
+		if json.Unmarshal(b, state) != nil {
+			state = &rateLimitState{Tokens: l.burst, Updated: now}
+		}
+	}
+
+	elapsed := now.Sub(state.Updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	tokens := math.Min(l.burst, state.Tokens+elapsed*l.rate) - 1
+
+	b, err := json.Marshal(&rateLimitState{Tokens: tokens, Updated: now})
+	if err != nil {
+		return 0, fmt.Errorf("Failed to marshal rate limit state : %s", err)
+	}
+	err = ioutil.WriteFile(path, b, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to write rate limit state : %s", err)
+	}
+
+	if tokens >= 0 {
+		return 0, nil
+	}
+	return time.Duration(-tokens / l.rate * float64(time.Second)), nil
+}
+
+// lock creates the lock file exclusively, which works on any platform.
+// A lock left by a crashed process is removed after a while.
+func (l *rateLimiter) lock() (func(), error) {
+	err := os.MkdirAll(l.dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create cache directory : %s", err)
+	}
+	path := filepath.Join(l.dir, "ratelimit.lock")
+	deadline := l.now().Add(rateLimitLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("Failed to lock rate limit state : %s", err)
+		}
+		if info, err := os.Stat(path); err == nil && l.now().Sub(info.ModTime()) > rateLimitStaleLock {
+			os.Remove(path)
+			continue
+		}
+		if l.now().After(deadline) {
+			return nil, fmt.Errorf("Failed to lock rate limit state : %s is held by another process", path)
+		}
+		time.Sleep(rateLimitLockRetry)
+	}
+}
@@ -0,0 +1,129 @@
+package pi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-ratelimit")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	slept := []time.Duration{}
+	limiter := &rateLimiter{
+		rate:  2,
+		burst: 2,
+		dir:   dir,
+		now:   func() time.Time { return now },
+		sleep: func(d time.Duration) { slept = append(slept, d) },
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := limiter.wait(); err != nil {
+			t.Fatalf("Unexpected error occurs. %s", err)
+		}
+	}
+	// the burst is consumed at once, then a token is added every 0.5 seconds.
+	if len(slept) != 2 || slept[0] != 500*time.Millisecond || slept[1] != time.Second {
+		t.Errorf("Unexpected waits. %v", slept)
+	}
+
+	now = now.Add(time.Hour)
+	slept = nil
+	limiter.wait()
+	if len(slept) != 0 {
+		t.Errorf("the bucket should be refilled. %v", slept)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ratelimit.lock")); !os.IsNotExist(err) {
+		t.Errorf("lock file should be removed. %v", err)
+	}
+}
+
+func TestRateLimiterAcrossProcesses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-ratelimit")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// each limiter stands for a process sharing the cache directory.
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	waits := []time.Duration{}
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter := &rateLimiter{rate: 10, burst: 1, dir: dir, now: func() time.Time { return now }}
+			d, err := limiter.reserve()
+			if err != nil {
+				t.Errorf("Unexpected error occurs. %s", err)
+			}
+			mu.Lock()
+			waits = append(waits, d)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	for i, d := range waits {
+		want := time.Duration(i) * 100 * time.Millisecond
+		if d < want-time.Millisecond || d > want+time.Millisecond {
+			t.Errorf("Unexpected waits. %v", waits)
+			break
+		}
+	}
+}
+
+func TestRateLimiterStaleLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-ratelimit")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ratelimit.lock")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatalf("Failed to write lock. %s", err)
+	}
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(path, old, old)
+
+	limiter := &rateLimiter{rate: 1, burst: 1, dir: dir, now: time.Now}
+	if _, err := limiter.reserve(); err != nil {
+		t.Errorf("stale lock should be removed. %s", err)
+	}
+}
+
+func TestNewRateLimiter(t *testing.T) {
+	before := os.Getenv("PI_RATE_LIMIT")
+	defer os.Setenv("PI_RATE_LIMIT", before)
+	beforeConfig := os.Getenv("PI_CONFIG")
+	os.Setenv("PI_CONFIG", "/path/to/not-found.json")
+	defer os.Setenv("PI_CONFIG", beforeConfig)
+
+	os.Setenv("PI_RATE_LIMIT", "")
+	if l, err := newRateLimiter(); err != nil || l != nil {
+		t.Errorf("rate should not be limited by default. %v %v", l, err)
+	}
+	os.Setenv("PI_RATE_LIMIT", "2.5")
+	l, err := newRateLimiter()
+	if err != nil || l == nil || l.rate != 2.5 || l.burst != 3 {
+		t.Errorf("Unexpected limiter. %+v %v", l, err)
+	}
+	os.Setenv("PI_RATE_LIMIT", "fast")
+	if _, err := newRateLimiter(); err == nil {
+		t.Errorf("expected error but not occurred")
+	}
+}
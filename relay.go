@@ -0,0 +1,323 @@
+package pi
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type relayCommand struct {
+	Serve relayServeCommand `description:"serve a relay receiving external events" command:"serve" subcommands-optional:"true"`
+}
+
+type relayServeCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	Addr     string `long:"addr" description:"The address to listen on." default:"127.0.0.1:8000"`
+	Config   string `short:"c" long:"config" description:"Path of the relay routes file (JSON)." required:"true"`
+	Insecure bool   `long:"insecure" description:"Accept unsigned requests to the routes without secret."`
+}
+
+type relayConfig struct {
+	Secret string       `json:"secret"`
+	Routes []relayRoute `json:"routes"`
+}
+
+// relayRoute maps requests to a path into an operation on a graph.
+type relayRoute struct {
+	Path          string `json:"path"`
+	Type          string `json:"type"`
+	Graph         string `json:"graph"`
+	Action        string `json:"action"`
+	QuantityField string `json:"quantityField"`
+	Secret        string `json:"secret"`
+}
+
+type relayServer struct {
+	username string
+	routes   map[string]relayRoute
+}
+
+const maxRelayPayloadSize = 1 << 20
+
+var relayRouteTypes = []string{"github-push", "json", "form"}
+var relayActions = []string{"increment", "decrement", "add", "subtract"}
+
+func (rS *relayServeCommand) Execute(args []string) error {
+	username, err := getUsername(rS.Username)
+	if err != nil {
+		return err
+	}
+	config, err := loadRelayConfig(rS.Config)
+	if err != nil {
+		return err
+	}
+
+	server, err := newRelayServer(username, config, rS.Insecure)
+	if err != nil {
+		return err
+	}
+	log.Printf("serving relay on %s", rS.Addr)
+	return http.ListenAndServe(rS.Addr, server)
+}
+
+// loadRelayConfig reads the routes file. The shared secret can also be given by `PI_RELAY_SECRET` environment variable.
+func loadRelayConfig(path string) (*relayConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read relay config : %s", err)
+	}
+	config := &relayConfig{}
+	err = json.Unmarshal(b, config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse relay config %s : %s", path, err)
+	}
+	if config.Secret == "" {
+		config.Secret = os.Getenv("PI_RELAY_SECRET")
+	}
+	return config, nil
+}
+
+// newRelayServer validates the routes. Routes without secret are refused unless `insecure` is set,
+// because anyone who can reach the relay could update the graphs.
+func newRelayServer(username string, config *relayConfig, insecure bool) (*relayServer, error) {
+	if len(config.Routes) == 0 {
+		return nil, fmt.Errorf("no routes are configured")
+	}
+
+	routes := map[string]relayRoute{}
+	for _, r := range config.Routes {
+		if !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("path of route must start with / : `%s`", r.Path)
+		}
+		if _, ok := routes[r.Path]; ok {
+			return nil, fmt.Errorf("route `%s` is duplicated", r.Path)
+		}
+		if !containsString(relayRouteTypes, r.Type) {
+			return nil, fmt.Errorf("unknown type `%s` of route `%s`. supported types are %s", r.Type, r.Path, strings.Join(relayRouteTypes, ", "))
+		}
+		if r.Action == "" {
+			r.Action = "increment"
+			if r.Type == "github-push" {
+				r.Action = "add"
+			}
+		}
+		if !containsString(relayActions, r.Action) {
+			return nil, fmt.Errorf("unknown action `%s` of route `%s`. supported actions are %s", r.Action, r.Path, strings.Join(relayActions, ", "))
+		}
+		if r.Graph == "" {
+			return nil, fmt.Errorf("graph of route `%s` is not specified", r.Path)
+		}
+		if (r.Action == "add" || r.Action == "subtract") && r.Type != "github-push" && r.QuantityField == "" {
+			return nil, fmt.Errorf("quantityField of route `%s` is required for action `%s`", r.Path, r.Action)
+		}
+		if r.Secret == "" {
+			r.Secret = config.Secret
+		}
+		if r.Secret == "" && !insecure {
+			return nil, fmt.Errorf("secret of route `%s` is not specified. specify --insecure to accept unsigned requests", r.Path)
+		}
+		routes[r.Path] = r
+	}
+
+	return &relayServer{username: username, routes: routes}, nil
+}
+
+func (s *relayServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, ok := s.routes[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRelayPayloadSize))
+	if err != nil {
+		http.Error(w, "Failed to read payload", http.StatusBadRequest)
+		return
+	}
+	if route.Secret != "" && !validRelaySignature(route.Secret, body, r.Header) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	// GitHub sends a ping event when the webhook is registered.
+	if route.Type == "github-push" && r.Header.Get("X-GitHub-Event") == "ping" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	quantity, err := relayQuantity(route, body, r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q, err := strconv.ParseFloat(quantity, 64); err == nil && q == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	req, err := s.generateRequest(route, quantity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the add and subtract APIs fall back to get and update as `pi pixel add` does.
+	ref := parseGraphRef(route.Graph, s.username)
+	var b []byte
+	switch route.Action {
+	case "add":
+		b, err = changePixel(req, ref.Username, ref.ID, quantity, 1)
+	case "subtract":
+		b, err = changePixel(req, ref.Username, ref.ID, quantity, -1)
+	default:
+		b, err = doRequestAndGetBody(req)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to %s %s : %s", route.Path, route.Action, route.Graph, err)
+		http.Error(w, "Failed to update graph", http.StatusBadGateway)
+		return
+	}
+	log.Printf("%s: %s %s %s", route.Path, route.Action, route.Graph, quantity)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// validRelaySignature accepts GitHub style `X-Hub-Signature-256: sha256=<hex>` or `X-Pi-Signature` with the same format.
+func validRelaySignature(secret string, body []byte, header http.Header) bool {
+	signature := header.Get("X-Hub-Signature-256")
+	if signature == "" {
+		signature = header.Get("X-Pi-Signature")
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// relayQuantity extracts the quantity from the payload. An empty string is returned for increment and decrement.
+func relayQuantity(route relayRoute, body []byte, contentType string) (string, error) {
+	if route.Type == "github-push" {
+		var payload struct {
+			Commits []json.RawMessage `json:"commits"`
+		}
+		err := json.Unmarshal(body, &payload)
+		if err != nil {
+			return "", fmt.Errorf("invalid push payload : %s", err)
+		}
+		if route.Action == "increment" || route.Action == "decrement" {
+			if len(payload.Commits) == 0 {
+				return "0", nil
+			}
+			return "", nil
+		}
+		return strconv.Itoa(len(payload.Commits)), nil
+	}
+
+	if route.Action == "increment" || route.Action == "decrement" {
+		return "", nil
+	}
+
+	var value interface{}
+	if route.Type == "form" {
+		values, err := parseRelayForm(body, contentType)
+		if err != nil {
+			return "", err
+		}
+		value = values.Get(route.QuantityField)
+	} else {
+		var payload interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		err := decoder.Decode(&payload)
+		if err != nil {
+			return "", fmt.Errorf("invalid json payload : %s", err)
+		}
+		value = lookupJSONField(payload, route.QuantityField)
+	}
+
+	var quantity string
+	switch v := value.(type) {
+	case json.Number:
+		quantity = v.String()
+	case string:
+		quantity = strings.TrimSpace(v)
+	}
+	if _, err := strconv.ParseFloat(quantity, 64); err != nil {
+		return "", fmt.Errorf("field `%s` is not a number", route.QuantityField)
+	}
+	return quantity, nil
+}
+
+func parseRelayForm(body []byte, contentType string) (url.Values, error) {
+	req, err := http.NewRequest("POST", "/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" {
+		contentType = "application/x-www-form-urlencoded"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		err = req.ParseMultipartForm(maxRelayPayloadSize)
+	} else {
+		err = req.ParseForm()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid form payload : %s", err)
+	}
+	return req.Form, nil
+}
+
+// lookupJSONField follows a dot separated path such as `data.amount`.
+func lookupJSONField(payload interface{}, path string) interface{} {
+	current := payload
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
+
+func (s *relayServer) generateRequest(route relayRoute, quantity string) (*http.Request, error) {
+	ref := parseGraphRef(route.Graph, s.username)
+	switch route.Action {
+	case "increment":
+		return generateIncrementPixelRequest(&incrementPixelCommand{Username: ref.Username, ID: ref.ID})
+	case "decrement":
+		return generateDecrementPixelRequest(&decrementPixelCommand{Username: ref.Username, ID: ref.ID})
+	case "subtract":
+		return generateSubtractPixelRequest(&subtractPixelCommand{Username: ref.Username, ID: ref.ID, Quantity: quantity})
+	default:
+		return generateAddPixelRequest(&addPixelCommand{Username: ref.Username, ID: ref.ID, Quantity: quantity})
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
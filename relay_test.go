@@ -0,0 +1,225 @@
+package pi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var relayTests = []struct {
+	name     string
+	input    []string
+	exitCode int
+}{
+	{
+		name:     "relay serve - not specify config",
+		input:    []string{"relay", "serve", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "relay serve - config not found",
+		input:    []string{"relay", "serve", "--username", "c-know", "--config", "/path/to/not-found.json"},
+		exitCode: 1,
+	},
+}
+
+func TestRelay(t *testing.T) {
+	for _, tt := range relayTests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestNewRelayServerValidation(t *testing.T) {
+	configs := map[string]relayRoute{
+		"relative path":   {Path: "github", Type: "github-push", Graph: "commits"},
+		"unknown type":    {Path: "/x", Type: "xml", Graph: "commits"},
+		"unknown action":  {Path: "/x", Type: "json", Graph: "commits", Action: "multiply"},
+		"no graph":        {Path: "/x", Type: "json"},
+		"no field to add": {Path: "/x", Type: "json", Graph: "sales", Action: "add"},
+	}
+	for name, route := range configs {
+		_, err := newRelayServer("c-know", &relayConfig{Routes: []relayRoute{route}}, true)
+		if err == nil {
+			t.Errorf("%s: expected error but not occurred", name)
+		}
+	}
+
+	_, err := newRelayServer("c-know", &relayConfig{Routes: []relayRoute{
+		{Path: "/x", Type: "json", Graph: "a"},
+		{Path: "/x", Type: "form", Graph: "b"},
+	}}, true)
+	if err == nil {
+		t.Errorf("duplicated routes: expected error but not occurred")
+	}
+
+	unsigned := &relayConfig{Secret: "shared-secret", Routes: []relayRoute{
+		{Path: "/x", Type: "json", Graph: "a", Secret: "secret"},
+		{Path: "/y", Type: "form", Graph: "b"},
+	}}
+	if _, err := newRelayServer("c-know", unsigned, false); err != nil {
+		t.Errorf("shared secret: unexpected error. %s", err)
+	}
+	unsigned.Secret = ""
+	if _, err := newRelayServer("c-know", unsigned, false); err == nil {
+		t.Errorf("route without secret: expected error but not occurred")
+	}
+	if _, err := newRelayServer("c-know", unsigned, true); err != nil {
+		t.Errorf("insecure: unexpected error. %s", err)
+	}
+}
+
+func TestRelayQuantity(t *testing.T) {
+	tests := []struct {
+		name        string
+		route       relayRoute
+		body        string
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{"push commits", relayRoute{Type: "github-push", Action: "add"}, `{"commits":[{"id":"a"},{"id":"b"}]}`, "", "2", false},
+		{"push increment", relayRoute{Type: "github-push", Action: "increment"}, `{"commits":[{"id":"a"}]}`, "", "", false},
+		{"push no commits", relayRoute{Type: "github-push", Action: "increment"}, `{"commits":[]}`, "", "0", false},
+		{"json nested field", relayRoute{Type: "json", Action: "add", QuantityField: "data.amount"}, `{"data":{"amount":12.5}}`, "", "12.5", false},
+		{"json string field", relayRoute{Type: "json", Action: "add", QuantityField: "amount"}, `{"amount":" 3 "}`, "", "3", false},
+		{"json missing field", relayRoute{Type: "json", Action: "add", QuantityField: "amount"}, `{"count":3}`, "", "", true},
+		{"json invalid", relayRoute{Type: "json", Action: "add", QuantityField: "amount"}, `amount=3`, "", "", true},
+		{"json increment", relayRoute{Type: "json", Action: "increment"}, `not json`, "", "", false},
+		{"form field", relayRoute{Type: "form", Action: "subtract", QuantityField: "count"}, `count=4&name=x`, "application/x-www-form-urlencoded", "4", false},
+		{"form not number", relayRoute{Type: "form", Action: "add", QuantityField: "count"}, `count=four`, "", "", true},
+	}
+	for _, tt := range tests {
+		got, err := relayQuantity(tt.route, []byte(tt.body), tt.contentType)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: unexpected error. %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: quantity=%s want=%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRelayServer(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.tokens["a-know"] = "thisissecret"
+	pixela.addGraph("c-know", graphDefinition{ID: "commits", Type: "int"})
+	pixela.addGraph("a-know", graphDefinition{ID: "sales", Type: "int"})
+	pixela.addGraph("c-know", graphDefinition{ID: "visits", Type: "int"})
+
+	server, err := newRelayServer("c-know", &relayConfig{
+		Secret: "shared-secret",
+		Routes: []relayRoute{
+			{Path: "/github", Type: "github-push", Graph: "commits"},
+			{Path: "/sales", Type: "json", Graph: "a-know/sales", Action: "add", QuantityField: "amount", Secret: "other-secret"},
+			{Path: "/visits", Type: "form", Graph: "visits"},
+		},
+	}, false)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+
+	sign := func(secret string, body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	post := func(path string, body string, header map[string]string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	push := `{"commits":[{"id":"a"},{"id":"b"},{"id":"c"}]}`
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		header map[string]string
+		status int
+	}{
+		{"push", "/github", push, map[string]string{"X-Hub-Signature-256": sign("shared-secret", push)}, http.StatusOK},
+		{"ping", "/github", `{}`, map[string]string{"X-Hub-Signature-256": sign("shared-secret", `{}`), "X-GitHub-Event": "ping"}, http.StatusNoContent},
+		{"no signature", "/github", push, nil, http.StatusUnauthorized},
+		{"wrong secret", "/sales", `{"amount":5}`, map[string]string{"X-Pi-Signature": sign("shared-secret", `{"amount":5}`)}, http.StatusUnauthorized},
+		{"json", "/sales", `{"amount":5}`, map[string]string{"X-Pi-Signature": sign("other-secret", `{"amount":5}`)}, http.StatusOK},
+		{"zero", "/sales", `{"amount":0.0}`, map[string]string{"X-Pi-Signature": sign("other-secret", `{"amount":0.0}`)}, http.StatusNoContent},
+		{"bad payload", "/sales", `{"amount":"x"}`, map[string]string{"X-Pi-Signature": sign("other-secret", `{"amount":"x"}`)}, http.StatusBadRequest},
+		{"form", "/visits", `a=1`, map[string]string{"X-Pi-Signature": sign("shared-secret", `a=1`), "Content-Type": "application/x-www-form-urlencoded"}, http.StatusOK},
+		{"unknown path", "/unknown", `{}`, nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if got := post(tt.path, tt.body, tt.header); got != tt.status {
+			t.Errorf("%s: status=%d want=%d", tt.name, got, tt.status)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/github", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Unexpected status for GET. %d", rec.Code)
+	}
+
+	want := []string{
+		"PUT /v1/users/c-know/graphs/commits/add",
+		"PUT /v1/users/a-know/graphs/sales/add",
+		"PUT /v1/users/c-know/graphs/visits/increment",
+	}
+	if got := pixela.requested(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected requests.\n%s", strings.Join(got, "\n"))
+	}
+	today, _ := (&graphDefinition{}).today()
+	date := today.Format(pixelDateLayout)
+	quantities := map[string]string{"c-know/commits": "3", "a-know/sales": "5", "c-know/visits": "1"}
+	for key, q := range quantities {
+		if got, _ := pixela.pixel(key, date); got.Quantity != q {
+			t.Errorf("Unexpected quantity of %s. %s want=%s", key, got.Quantity, q)
+		}
+	}
+}
+
+func TestRelayServerFallback(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.addGraph("c-know", graphDefinition{ID: "sales", Type: "int"})
+	pixela.noAdd = true
+	today, _ := (&graphDefinition{}).today()
+	date := today.Format(pixelDateLayout)
+	pixela.setPixel("c-know/sales", date, pixelBody{Quantity: "10", OptionalData: `{"note":"keep"}`})
+
+	server, err := newRelayServer("c-know", &relayConfig{Routes: []relayRoute{
+		{Path: "/sales", Type: "json", Graph: "sales", Action: "subtract", QuantityField: "amount"},
+	}}, true)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+
+	// the server without add and subtract api is updated by get and update.
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("POST", "/sales", strings.NewReader(`{"amount":3}`)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Unexpected status. %d %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := pixela.pixel("c-know/sales", date); got != (pixelBody{Quantity: "7", OptionalData: `{"note":"keep"}`}) {
+		t.Errorf("Unexpected pixel. %v", got)
+	}
+	if got := pixela.requested(); len(got) == 0 || got[0] != "PUT /v1/users/c-know/graphs/sales/subtract" || got[len(got)-1] != "PUT /v1/users/c-know/graphs/sales/"+date {
+		t.Errorf("Unexpected requests.\n%s", strings.Join(got, "\n"))
+	}
+}
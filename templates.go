@@ -0,0 +1,206 @@
+package pi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type templatesCommand struct {
+	List     listTemplatesCommand    `description:"list Graph templates" command:"list" subcommands-optional:"true"`
+	Show     showTemplateCommand     `description:"show a Graph template" command:"show" subcommands-optional:"true"`
+	SaveFrom saveTemplateFromCommand `description:"save the definition of a Graph as a template" command:"save-from" subcommands-optional:"true"`
+}
+
+type listTemplatesCommand struct{}
+
+type showTemplateCommand struct{}
+
+type saveTemplateFromCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	Name     string `short:"n" long:"name" description:"The name of the template. The graph ID is used if omitted."`
+}
+
+// graphTemplate has the fields of `graphs create` except the ID.
+type graphTemplate struct {
+	Name           string `json:"name,omitempty"`
+	Unit           string `json:"unit,omitempty"`
+	Type           string `json:"type,omitempty"`
+	Color          string `json:"color,omitempty"`
+	Timezone       string `json:"timezone,omitempty"`
+	SelfSufficient string `json:"selfSufficient,omitempty"`
+}
+
+func (lT *listTemplatesCommand) Execute(args []string) error {
+	templates, err := loadGraphTemplates()
+	if err != nil {
+		return err
+	}
+	printGraphTemplates(os.Stdout, templates)
+	return nil
+}
+
+func (sT *showTemplateCommand) Execute(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("specify the template name")
+	}
+	template, err := findGraphTemplate(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to marshal template : %s", err)
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+func (sF *saveTemplateFromCommand) Execute(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("specify the graph ID")
+	}
+	username, err := getUsername(sF.Username)
+	if err != nil {
+		return err
+	}
+	def, err := fetchGraphDefinition(username, args[0])
+	if err != nil {
+		return err
+	}
+
+	name := sF.Name
+	if name == "" {
+		name = def.ID
+	}
+	err = saveGraphTemplate(name, graphTemplateFromDefinition(def))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("saved template `%s` from %s\n", name, def.ID)
+	return nil
+}
+
+// builtinGraphTemplates are available without configuration. They can be overridden by the config file or saved templates.
+var builtinGraphTemplates = map[string]graphTemplate{
+	"habit":     {Unit: "times", Type: "int", Color: "shibafu"},
+	"minutes":   {Unit: "minutes", Type: "int", Color: "sora"},
+	"weight-kg": {Unit: "kg", Type: "float", Color: "kuro"},
+}
+
+func graphTemplateFromDefinition(def *graphDefinition) *graphTemplate {
+	return &graphTemplate{
+		Name:           def.Name,
+		Unit:           def.Unit,
+		Type:           def.Type,
+		Color:          def.Color,
+		Timezone:       def.Timezone,
+		SelfSufficient: def.SelfSufficient,
+	}
+}
+
+// apply fills the fields of `cG` which are not specified by the flags.
+func (t *graphTemplate) apply(cG *createGraphCommand) {
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&cG.Name, t.Name)
+	fill(&cG.Unit, t.Unit)
+	fill(&cG.Type, t.Type)
+	fill(&cG.Color, t.Color)
+	fill(&cG.Timezone, t.Timezone)
+	fill(&cG.SelfSufficient, t.SelfSufficient)
+}
+
+// loadGraphTemplates merges the built-in templates, `templates` of the config file and the templates saved by `pi templates save-from`.
+// The saved ones take precedence, then the config file.
+func loadGraphTemplates() (map[string]*graphTemplate, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	templates := map[string]*graphTemplate{}
+	for name, t := range builtinGraphTemplates {
+		t := t
+		templates[name] = &t
+	}
+	for name, t := range config.Templates {
+		if t == nil {
+			return nil, fmt.Errorf("template `%s` of the config file must be an object", name)
+		}
+		templates[name] = t
+	}
+
+	saved, err := loadSavedGraphTemplates()
+	if err != nil {
+		return nil, err
+	}
+	for name, t := range saved {
+		templates[name] = t
+	}
+	return templates, nil
+}
+
+func loadSavedGraphTemplates() (map[string]*graphTemplate, error) {
+	templates := map[string]*graphTemplate{}
+	err := loadDataFile("templates.json", "templates", &templates)
+	if err != nil {
+		return nil, err
+	}
+	for name, t := range templates {
+		if t == nil {
+			return nil, fmt.Errorf("template `%s` of the saved templates must be an object", name)
+		}
+	}
+	return templates, nil
+}
+
+func findGraphTemplate(name string) (*graphTemplate, error) {
+	templates, err := loadGraphTemplates()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template `%s` is not found", name)
+	}
+	return t, nil
+}
+
+func saveGraphTemplate(name string, template *graphTemplate) error {
+	templates, err := loadSavedGraphTemplates()
+	if err != nil {
+		return err
+	}
+	templates[name] = template
+	return saveDataFile("templates.json", "templates", templates)
+}
+
+func printGraphTemplates(out io.Writer, templates map[string]*graphTemplate) {
+	names := []string{}
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "template\tname\tunit\ttype\tcolor\ttimezone\tself-sufficient")
+	for _, name := range names {
+		t := templates[name]
+		fmt.Fprintf(w, "%s\n", strings.Join([]string{name, dash(t.Name), dash(t.Unit), dash(t.Type), dash(t.Color), dash(t.Timezone), dash(t.SelfSufficient)}, "\t"))
+	}
+	w.Flush()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
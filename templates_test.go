@@ -0,0 +1,147 @@
+package pi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func prepareTemplates(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "pi-templates")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{"templates":{"minutes":{"unit":"minutes","type":"int","color":"sora","timezone":"Asia/Tokyo"},"weight-kg":{"name":"Weight","unit":"kg","type":"float","color":"momiji"}}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config. %s", err)
+	}
+
+	beforeConfigEnv := os.Getenv("PI_CONFIG")
+	beforeDataDirEnv := os.Getenv("PI_DATA_DIR")
+	os.Setenv("PI_CONFIG", path)
+	os.Setenv("PI_DATA_DIR", dir)
+	return func() {
+		os.Setenv("PI_CONFIG", beforeConfigEnv)
+		os.Setenv("PI_DATA_DIR", beforeDataDirEnv)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestTemplatesCommand(t *testing.T) {
+	defer prepareTemplates(t)()
+
+	tests := []struct {
+		name     string
+		input    []string
+		exitCode int
+	}{
+		{"list templates", []string{"templates", "list"}, 0},
+		{"show template", []string{"templates", "show", "minutes"}, 0},
+		{"show template - not specify name", []string{"templates", "show"}, 1},
+		{"show template - built-in", []string{"templates", "show", "habit"}, 0},
+		{"show template - not found", []string{"templates", "show", "unknown"}, 1},
+		{"save template - not specify graph-id", []string{"templates", "save-from", "--username", "c-know"}, 1},
+		{"create graph - template not found", []string{"graphs", "create", "--graph-id", "test-id", "--name", "test-name", "--template", "unknown", "--username", "c-know"}, 1},
+		{"create graph - template lacks name", []string{"graphs", "create", "--graph-id", "test-id", "--template", "minutes", "--username", "c-know"}, 1},
+	}
+	for _, tt := range tests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestGenerateCreateGraphRequestWithTemplate(t *testing.T) {
+	defer prepareTemplates(t)()
+	beforeAPIBaseEnv, beforeTokenEnv, _, _ := prepare()
+	defer cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	cmd := &createGraphCommand{
+		Username: "c-know",
+		ID:       "reading",
+		Name:     "Reading",
+		Color:    "shibafu",
+		Template: "minutes",
+	}
+	req, err := generateCreateGraphRequest(cmd)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		t.Errorf("Failed to read request body. %s", err)
+	}
+	if string(b) != `{"id":"reading","name":"Reading","unit":"minutes","type":"int","color":"shibafu","timezone":"Asia/Tokyo","selfSufficient":""}` {
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+	if cmd.Unit != "" {
+		t.Errorf("the command should not be modified. %+v", cmd)
+	}
+}
+
+func TestSaveGraphTemplate(t *testing.T) {
+	defer prepareTemplates(t)()
+
+	err := saveGraphTemplate("weight-kg", graphTemplateFromDefinition(&graphDefinition{ID: "weight", Name: "Body weight", Unit: "kg", Type: "float", Color: "kuro"}))
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	err = saveGraphTemplate("habit", &graphTemplate{Unit: "times", Type: "int", Color: "shibafu"})
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+
+	templates, err := loadGraphTemplates()
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if len(templates) != 3 {
+		t.Errorf("Unexpected templates. %v", templates)
+	}
+	if templates["weight-kg"].Color != "kuro" {
+		t.Errorf("the saved template should take precedence. %+v", templates["weight-kg"])
+	}
+
+	out := &bytes.Buffer{}
+	printGraphTemplates(out, templates)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Unexpected output. %s", out.String())
+	}
+	if got := strings.Join(strings.Fields(lines[1]), " "); got != "habit - times int shibafu - -" {
+		t.Errorf("Unexpected line. %s", got)
+	}
+}
+
+func TestLoadGraphTemplates(t *testing.T) {
+	defer prepareTemplates(t)()
+
+	templates, err := loadGraphTemplates()
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if templates["habit"] == nil || templates["habit"].Unit != "times" {
+		t.Errorf("built-in template should be loaded. %+v", templates["habit"])
+	}
+	if templates["weight-kg"].Color != "momiji" {
+		t.Errorf("the config file should take precedence over built-in templates. %+v", templates["weight-kg"])
+	}
+
+	os.Setenv("PI_CONFIG", filepath.Join(os.Getenv("PI_DATA_DIR"), "null.json"))
+	err = ioutil.WriteFile(os.Getenv("PI_CONFIG"), []byte(`{"templates":{"habit":null}}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config. %s", err)
+	}
+	if _, err := loadGraphTemplates(); err == nil {
+		t.Errorf("null template: expected error but not occurred")
+	}
+}
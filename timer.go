@@ -0,0 +1,197 @@
+package pi
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type timerCommand struct {
+	Start  startTimerCommand  `description:"start a timer" command:"start" subcommands-optional:"true"`
+	Stop   stopTimerCommand   `description:"stop a timer and record the elapsed time" command:"stop" subcommands-optional:"true"`
+	Status timerStatusCommand `description:"show running timers" command:"status" subcommands-optional:"true"`
+}
+
+type startTimerCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+	ID       string `short:"g" long:"graph-id" description:"ID for identifying the pixelation graph." required:"true"`
+	Unit     string `long:"unit" description:"The unit in which the elapsed time is recorded." choice:"minutes" choice:"hours" default:"minutes"`
+}
+
+type stopTimerCommand struct {
+	ID      string `short:"g" long:"graph-id" description:"ID of the graph of the timer. It can be omitted if only one timer is running."`
+	Discard bool   `long:"discard" description:"Stop the timer without recording the elapsed time."`
+}
+
+type timerStatusCommand struct{}
+
+type runningTimer struct {
+	Username string    `json:"username"`
+	GraphID  string    `json:"graphID"`
+	Unit     string    `json:"unit"`
+	Start    time.Time `json:"start"`
+}
+
+type timerState struct {
+	Timers []runningTimer `json:"timers"`
+}
+
+var timerNow = time.Now
+
+func (sT *startTimerCommand) Execute(args []string) error {
+	username, err := getUsername(sT.Username)
+	if err != nil {
+		return err
+	}
+
+	state, err := loadTimerState()
+	if err != nil {
+		return err
+	}
+	if _, ok := state.find(sT.ID); ok {
+		return fmt.Errorf("the timer of graph `%s` is already running", sT.ID)
+	}
+
+	timer := runningTimer{Username: username, GraphID: sT.ID, Unit: sT.Unit, Start: timerNow()}
+	state.Timers = append(state.Timers, timer)
+	err = saveTimerState(state)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("started the timer of %s at %s\n", sT.ID, timer.Start.Format(time.RFC3339))
+	return nil
+}
+
+func (sT *stopTimerCommand) Execute(args []string) error {
+	state, err := loadTimerState()
+	if err != nil {
+		return err
+	}
+
+	id := sT.ID
+	if id == "" {
+		if len(state.Timers) != 1 {
+			return fmt.Errorf("%d timers are running. please specify --graph-id,-g", len(state.Timers))
+		}
+		id = state.Timers[0].GraphID
+	}
+	i, ok := state.find(id)
+	if !ok {
+		return fmt.Errorf("the timer of graph `%s` is not running", id)
+	}
+	timer := state.Timers[i]
+	end := timerNow()
+
+	if !sT.Discard {
+		recorded, err := recordTimer(timer, end)
+		if err != nil {
+			// keep the timer from the unrecorded time, so that it can be stopped again.
+			state.Timers[i].Start = recorded
+			if saveErr := saveTimerState(state); saveErr != nil {
+				return saveErr
+			}
+			return err
+		}
+	}
+
+	state.Timers = append(state.Timers[:i], state.Timers[i+1:]...)
+	err = saveTimerState(state)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("stopped the timer of %s (%s)\n", id, end.Sub(timer.Start).Round(time.Second))
+	return nil
+}
+
+func (tS *timerStatusCommand) Execute(args []string) error {
+	state, err := loadTimerState()
+	if err != nil {
+		return err
+	}
+	if len(state.Timers) == 0 {
+		fmt.Println("no timers are running")
+		return nil
+	}
+
+	now := timerNow()
+	for _, t := range state.Timers {
+		fmt.Printf("%s/%s\tstarted at %s\t%s elapsed\n", t.Username, t.GraphID, t.Start.Format(time.RFC3339), now.Sub(t.Start).Round(time.Second))
+	}
+	return nil
+}
+
+// recordTimer adds the elapsed time to the pixels of each day.
+// It returns the time until which the elapsed time is recorded.
+func recordTimer(timer runningTimer, end time.Time) (time.Time, error) {
+	recorded := timer.Start
+	def, err := fetchGraphDefinition(timer.Username, timer.GraphID)
+	if err != nil {
+		return recorded, err
+	}
+	loc, err := def.location()
+	if err != nil {
+		return recorded, err
+	}
+
+	for _, d := range splitByDay(timer.Start, end, loc) {
+		quantity := d.Quantity.Minutes()
+		if timer.Unit == "hours" {
+			quantity = d.Quantity.Hours()
+		}
+		if def.Type == "float" {
+			quantity = math.Round(quantity*100) / 100
+		}
+		if def.formatQuantity(quantity) != def.formatQuantity(0) {
+			total, _, err := addPixelQuantity(timer.Username, def, d.Date, quantity)
+			if err != nil {
+				return recorded, fmt.Errorf("Failed to record the elapsed time of %s : %s", d.Date, err)
+			}
+			fmt.Printf("%s\t+%s = %s\n", d.Date, def.formatQuantity(quantity), total)
+		}
+		recorded = recorded.Add(d.Quantity)
+	}
+	return recorded, nil
+}
+
+type dailyDuration struct {
+	Date     string
+	Quantity time.Duration
+}
+
+// splitByDay splits the period at midnights of `loc`.
+func splitByDay(start time.Time, end time.Time, loc *time.Location) []dailyDuration {
+	result := []dailyDuration{}
+	current := start.In(loc)
+	end = end.In(loc)
+	for current.Before(end) {
+		next := time.Date(current.Year(), current.Month(), current.Day()+1, 0, 0, 0, 0, loc)
+		if next.After(end) {
+			next = end
+		}
+		result = append(result, dailyDuration{Date: current.Format(pixelDateLayout), Quantity: next.Sub(current)})
+		current = next
+	}
+	return result
+}
+
+func (s *timerState) find(id string) (int, bool) {
+	for i, t := range s.Timers {
+		if t.GraphID == id {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func loadTimerState() (*timerState, error) {
+	state := &timerState{}
+	err := loadDataFile("timer.json", "timer state", state)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func saveTimerState(state *timerState) error {
+	return saveDataFile("timer.json", "timer state", state)
+}
@@ -0,0 +1,178 @@
+package pi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+var timerTests = []struct {
+	name     string
+	input    []string
+	exitCode int
+}{
+	{
+		name:     "start timer - not specify id",
+		input:    []string{"timer", "start", "--username", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "start timer - invalid unit",
+		input:    []string{"timer", "start", "--username", "c-know", "--graph-id", "test-id", "--unit", "days"},
+		exitCode: 1,
+	},
+}
+
+func TestTimer(t *testing.T) {
+	for _, tt := range timerTests {
+		exitCode := (&CLI{
+			ErrStream: ioutil.Discard,
+			OutStream: ioutil.Discard,
+		}).Run(tt.input)
+		if exitCode != tt.exitCode {
+			t.Errorf("%s(exitCode): out=%d want=%d", tt.name, exitCode, tt.exitCode)
+		}
+	}
+}
+
+func TestSplitByDay(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Failed to load location. %s", err)
+	}
+
+	// 2019-01-01 23:30 - 2019-01-03 00:15 in Asia/Tokyo
+	start := time.Date(2019, 1, 1, 14, 30, 0, 0, time.UTC)
+	end := time.Date(2019, 1, 2, 15, 15, 0, 0, time.UTC)
+	got := splitByDay(start, end, loc)
+	want := []dailyDuration{
+		{Date: "20190101", Quantity: 30 * time.Minute},
+		{Date: "20190102", Quantity: 24 * time.Hour},
+		{Date: "20190103", Quantity: 15 * time.Minute},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Unexpected split. %v", got)
+	}
+
+	if got := splitByDay(end, end, loc); len(got) != 0 {
+		t.Errorf("Unexpected split of empty period. %v", got)
+	}
+}
+
+func TestTimerStartAndStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pi-timer")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	beforeDataDirEnv := os.Getenv("PI_DATA_DIR")
+	os.Setenv("PI_DATA_DIR", dir)
+	defer os.Setenv("PI_DATA_DIR", beforeDataDirEnv)
+
+	now := time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC)
+	timerNow = func() time.Time { return now }
+	defer func() { timerNow = time.Now }()
+
+	err = (&startTimerCommand{Username: "c-know", ID: "test-id", Unit: "minutes"}).Execute(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	err = (&startTimerCommand{Username: "c-know", ID: "test-id", Unit: "minutes"}).Execute(nil)
+	if err == nil {
+		t.Errorf("expected error for duplicated timer but not occurred")
+	}
+	err = (&startTimerCommand{Username: "c-know", ID: "other-id", Unit: "hours"}).Execute(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+
+	state, err := loadTimerState()
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if len(state.Timers) != 2 || state.Timers[1] != (runningTimer{Username: "c-know", GraphID: "other-id", Unit: "hours", Start: now}) {
+		t.Errorf("Unexpected timer state. %+v", state)
+	}
+
+	err = (&stopTimerCommand{Discard: true}).Execute(nil)
+	if err == nil {
+		t.Errorf("expected error for ambiguous timer but not occurred")
+	}
+	err = (&stopTimerCommand{ID: "test-id", Discard: true}).Execute(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	err = (&stopTimerCommand{Discard: true}).Execute(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+
+	state, err = loadTimerState()
+	if err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if len(state.Timers) != 0 {
+		t.Errorf("Unexpected timer state. %+v", state)
+	}
+}
+
+func TestRecordTimer(t *testing.T) {
+	pixela, stop := startFakePixela()
+	defer stop()
+	pixela.addGraph("c-know", graphDefinition{ID: "int-id", Type: "int"})
+	pixela.addGraph("c-know", graphDefinition{ID: "float-id", Type: "float", Timezone: "Asia/Tokyo"})
+	pixela.setPixel("c-know/int-id", "20190101", pixelBody{Quantity: "30", OptionalData: `{"a":1}`})
+
+	// 23:30 to 01:10 of the next day in UTC.
+	start := time.Date(2019, 1, 1, 23, 30, 0, 0, time.UTC)
+	end := time.Date(2019, 1, 2, 1, 10, 0, 0, time.UTC)
+	recorded, err := recordTimer(runningTimer{Username: "c-know", GraphID: "int-id", Unit: "minutes", Start: start}, end)
+	if err != nil || !recorded.Equal(end) {
+		t.Fatalf("Unexpected result. %s %v", recorded, err)
+	}
+	// the minutes are added to the registered pixel, and the pixel of the next day is created.
+	if got, _ := pixela.pixel("c-know/int-id", "20190101"); got != (pixelBody{Quantity: "60", OptionalData: `{"a":1}`}) {
+		t.Errorf("Unexpected pixel of the first day. %v", got)
+	}
+	if got, _ := pixela.pixel("c-know/int-id", "20190102"); got != (pixelBody{Quantity: "70"}) {
+		t.Errorf("Unexpected pixel of the second day. %v", got)
+	}
+
+	// the hours are rounded to 2 decimal places, and split by the day in the graph's timezone.
+	start = time.Date(2019, 1, 1, 14, 40, 0, 0, time.UTC)
+	end = time.Date(2019, 1, 1, 15, 30, 0, 0, time.UTC)
+	if _, err := recordTimer(runningTimer{Username: "c-know", GraphID: "float-id", Unit: "hours", Start: start}, end); err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if got, _ := pixela.pixel("c-know/float-id", "20190101"); got.Quantity != "0.33" {
+		t.Errorf("Unexpected pixel of the first day. %v", got)
+	}
+	if got, _ := pixela.pixel("c-know/float-id", "20190102"); got.Quantity != "0.5" {
+		t.Errorf("Unexpected pixel of the second day. %v", got)
+	}
+
+	// less than a minute is not recorded on an int graph.
+	pixela.requests = nil
+	start = time.Date(2019, 1, 3, 10, 0, 0, 0, time.UTC)
+	if _, err := recordTimer(runningTimer{Username: "c-know", GraphID: "int-id", Unit: "minutes", Start: start}, start.Add(20*time.Second)); err != nil {
+		t.Fatalf("Unexpected error occurs. %s", err)
+	}
+	if _, ok := pixela.pixel("c-know/int-id", "20190103"); ok || len(pixela.requested()) != 1 {
+		t.Errorf("the elapsed time rounded to zero should not be recorded. %v", pixela.requested())
+	}
+
+	// the time until the failed day is returned.
+	start = time.Date(2019, 1, 4, 23, 0, 0, 0, time.UTC)
+	pixela.onGetPixel = func(key string, date string) {
+		if date == "20190105" {
+			pixela.pixels[key][date] = pixelBody{Quantity: pixela.pixels[key][date].Quantity + "0"}
+		}
+	}
+	pixela.setPixel("c-know/int-id", "20190105", pixelBody{Quantity: "1"})
+	recorded, err = recordTimer(runningTimer{Username: "c-know", GraphID: "int-id", Unit: "minutes", Start: start}, start.Add(2*time.Hour))
+	if err == nil || !recorded.Equal(time.Date(2019, 1, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected result. %s %v", recorded, err)
+	}
+}
@@ -0,0 +1,487 @@
+package pi
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type tuiCommand struct {
+	Username string `short:"u" long:"username" description:"User name of graph owner."`
+}
+
+// tui keeps the state of the screen. Requests are built by the same functions as the commands.
+type tui struct {
+	username    string
+	graphs      []graphDefinition
+	selected    int
+	pixels      []pixel
+	prompt      *tuiPrompt
+	message     string
+	do          func(req *http.Request) ([]byte, error)
+	fetchGraphs func(username string) ([]graphDefinition, error)
+	fetchPixels func(username string, id string, from string, to string) ([]pixel, error)
+	now         func() time.Time
+}
+
+type tuiPrompt struct {
+	labels  []string
+	values  []string
+	current int
+	input   string
+	submit  func(values []string) error
+}
+
+const (
+	tuiListWidth      = 24
+	tuiHeatmapWeeks   = 20
+	tuiRecentPixels   = 10
+	tuiHelp           = "↑↓ select  p post  u update  d delete  i increment  x decrement  e edit graph  r reload  q quit"
+	tuiResetColor     = "\x1b[0m"
+	tuiReverse        = "\x1b[7m"
+	tuiClearScreen    = "\x1b[H\x1b[2J"
+	tuiEnterAltScreen = "\x1b[?1049h\x1b[?25l"
+	tuiLeaveAltScreen = "\x1b[?25h\x1b[?1049l"
+	tuiEmptyPixelBg   = 236
+	tuiHeatmapLevels  = 4
+)
+
+// 256 color palette of the pixels from light to dark, for each graph color.
+var tuiHeatmapColors = map[string][]int{
+	"shibafu": {22, 28, 34, 46},
+	"momiji":  {52, 88, 160, 196},
+	"sora":    {17, 19, 27, 39},
+	"ichou":   {58, 136, 178, 226},
+	"ajisai":  {54, 91, 128, 171},
+	"kuro":    {240, 245, 250, 255},
+}
+
+var graphColors = []string{"shibafu", "momiji", "sora", "ichou", "ajisai", "kuro"}
+
+func (tC *tuiCommand) Execute(args []string) error {
+	username, err := getUsername(tC.Username)
+	if err != nil {
+		return err
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("Failed to open terminal : %s", err)
+	}
+	defer tty.Close()
+	restore, err := makeRawTerminal(tty)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	fmt.Fprint(tty, tuiEnterAltScreen)
+	defer fmt.Fprint(tty, tuiLeaveAltScreen)
+
+	t := newTUI(username)
+	t.reloadGraphs()
+
+	reader := bufio.NewReader(tty)
+	for {
+		width, height := terminalSize(tty)
+		fmt.Fprint(tty, tuiClearScreen+strings.Join(t.render(width, height), "\r\n"))
+
+		key, err := readTUIKey(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !t.handleKey(key) {
+			return nil
+		}
+	}
+}
+
+func newTUI(username string) *tui {
+	return &tui{
+		username:    username,
+		do:          doRequestAndGetBody,
+		fetchGraphs: fetchGraphDefinitions,
+		fetchPixels: fetchGraphPixels,
+		now:         time.Now,
+	}
+}
+
+func (t *tui) graph() *graphDefinition {
+	if t.selected < 0 || t.selected >= len(t.graphs) {
+		return nil
+	}
+	return &t.graphs[t.selected]
+}
+
+func (t *tui) reloadGraphs() {
+	graphs, err := t.fetchGraphs(t.username)
+	if err != nil {
+		t.message = strings.TrimSpace(err.Error())
+		return
+	}
+	t.graphs = graphs
+	if t.selected >= len(graphs) {
+		t.selected = 0
+	}
+	t.reloadPixels()
+}
+
+func (t *tui) reloadPixels() {
+	t.pixels = nil
+	g := t.graph()
+	if g == nil {
+		return
+	}
+	today, err := t.today(g)
+	if err != nil {
+		t.message = err.Error()
+		return
+	}
+	from := today.AddDate(0, 0, -7*tuiHeatmapWeeks)
+	pixels, err := t.fetchPixels(t.username, g.ID, from.Format(pixelDateLayout), today.Format(pixelDateLayout))
+	if err != nil {
+		t.message = strings.TrimSpace(err.Error())
+		return
+	}
+	t.pixels = pixels
+}
+
+func (t *tui) today(g *graphDefinition) (time.Time, error) {
+	loc, err := g.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := t.now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), nil
+}
+
+// handleKey updates the state by the key. It returns false when the TUI should exit.
+func (t *tui) handleKey(key string) bool {
+	if t.prompt != nil {
+		t.handlePromptKey(key)
+		return true
+	}
+
+	t.message = ""
+	g := t.graph()
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "up", "k":
+		if t.selected > 0 {
+			t.selected--
+			t.reloadPixels()
+		}
+	case "down", "j":
+		if t.selected < len(t.graphs)-1 {
+			t.selected++
+			t.reloadPixels()
+		}
+	case "r":
+		t.reloadGraphs()
+	}
+	if g == nil {
+		return true
+	}
+
+	today := ""
+	if d, err := t.today(g); err == nil {
+		today = d.Format(pixelDateLayout)
+	}
+	switch key {
+	case "p":
+		t.startPrompt([]string{"date", "quantity"}, []string{today, ""}, func(v []string) error {
+			return t.request(generatePostPixelRequest(&postPixelCommand{Username: t.username, ID: g.ID, Date: v[0], Quantity: v[1]}))
+		})
+	case "u":
+		t.startPrompt([]string{"date", "quantity"}, []string{today, ""}, func(v []string) error {
+			return t.request(generateUpdatePixelRequest(&updatePixelCommand{Username: t.username, ID: g.ID, Date: v[0], Quantity: v[1]}))
+		})
+	case "d":
+		t.startPrompt([]string{"date", "delete? (y/N)"}, []string{today, ""}, func(v []string) error {
+			if strings.ToLower(v[1]) != "y" {
+				return nil
+			}
+			return t.request(generateDeletePixelRequest(&deletePixelCommand{Username: t.username, ID: g.ID, Date: v[0]}))
+		})
+	case "i":
+		t.message = t.result(t.request(generateIncrementPixelRequest(&incrementPixelCommand{Username: t.username, ID: g.ID})))
+	case "x":
+		t.message = t.result(t.request(generateDecrementPixelRequest(&decrementPixelCommand{Username: t.username, ID: g.ID})))
+	case "e":
+		t.startPrompt([]string{"name", "unit", "color"}, []string{g.Name, g.Unit, g.Color}, func(v []string) error {
+			cmd := &updateGraphCommand{Username: t.username, ID: g.ID}
+			if v[0] != g.Name {
+				cmd.Name = v[0]
+			}
+			if v[1] != g.Unit {
+				cmd.Unit = v[1]
+			}
+			if v[2] != g.Color {
+				if !containsString(graphColors, v[2]) {
+					return fmt.Errorf("color must be one of %s", strings.Join(graphColors, ", "))
+				}
+				cmd.Color = v[2]
+			}
+			if cmd.Name == "" && cmd.Unit == "" && cmd.Color == "" {
+				return nil
+			}
+			err := t.request(generateUpdateGraphRequest(cmd))
+			if err == nil {
+				t.reloadGraphs()
+			}
+			return err
+		})
+	}
+	return true
+}
+
+func (t *tui) startPrompt(labels []string, defaults []string, submit func(values []string) error) {
+	t.prompt = &tuiPrompt{labels: labels, values: defaults, input: defaults[0], submit: submit}
+}
+
+func (t *tui) handlePromptKey(key string) {
+	p := t.prompt
+	switch key {
+	case "esc", "ctrl-c":
+		t.prompt = nil
+		t.message = "canceled"
+	case "backspace":
+		if p.input != "" {
+			_, size := utf8.DecodeLastRuneInString(p.input)
+			p.input = p.input[:len(p.input)-size]
+		}
+	case "enter":
+		p.values[p.current] = p.input
+		p.current++
+		if p.current < len(p.labels) {
+			p.input = p.values[p.current]
+			return
+		}
+		t.prompt = nil
+		t.message = t.result(p.submit(p.values))
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			p.input += key
+		}
+	}
+}
+
+// request sends the request and reloads the pixels. The message of the response is kept to be shown.
+func (t *tui) request(req *http.Request, err error) error {
+	if err != nil {
+		return err
+	}
+	b, err := t.do(req)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(b, &resp) == nil && resp.Message != "" {
+		t.message = resp.Message
+	}
+	t.reloadPixels()
+	return nil
+}
+
+func (t *tui) result(err error) string {
+	if err != nil {
+		return strings.TrimSpace(err.Error())
+	}
+	return t.message
+}
+
+// render returns the lines of the screen.
+func (t *tui) render(width int, height int) []string {
+	right := t.renderGraph()
+	lines := []string{tuiReverse + padRight(fmt.Sprintf(" pi tui - %s", t.username), width) + tuiResetColor}
+	for i := 0; i < height-3; i++ {
+		left := ""
+		if i < len(t.graphs) {
+			cursor := "  "
+			if i == t.selected {
+				cursor = "> "
+			}
+			left = cursor + t.graphs[i].ID
+		}
+		line := padRight(left, tuiListWidth)
+		if i < len(right) {
+			line += right[i]
+		}
+		lines = append(lines, line)
+	}
+
+	status := t.message
+	if t.prompt != nil {
+		status = fmt.Sprintf("%s: %s_", t.prompt.labels[t.prompt.current], t.prompt.input)
+	}
+	return append(lines, padRight(status, width), tuiReverse+padRight(tuiHelp, width)+tuiResetColor)
+}
+
+func (t *tui) renderGraph() []string {
+	g := t.graph()
+	if g == nil {
+		return []string{"no graphs"}
+	}
+	lines := []string{
+		fmt.Sprintf("%s (%s)", g.Name, g.ID),
+		fmt.Sprintf("unit: %s  type: %s  color: %s  timezone: %s", g.Unit, g.Type, g.Color, g.Timezone),
+		"",
+	}
+	today, err := t.today(g)
+	if err != nil {
+		return append(lines, err.Error())
+	}
+	quantities, err := pixelQuantities(t.pixels)
+	if err != nil {
+		return append(lines, err.Error())
+	}
+	lines = append(lines, renderHeatmap(quantities, today, tuiHeatmapWeeks, tuiHeatmapColors[g.Color])...)
+
+	lines = append(lines, "", "recent pixels")
+	for i := len(t.pixels) - 1; i >= 0 && i >= len(t.pixels)-tuiRecentPixels; i-- {
+		p := t.pixels[i]
+		lines = append(lines, strings.TrimSpace(fmt.Sprintf("%s  %10s  %s", p.Date, p.Quantity, p.OptionalData)))
+	}
+	return lines
+}
+
+// renderHeatmap draws the pixels of the weeks until `today` in rows of weekdays, as the SVG graph of Pixela does.
+func renderHeatmap(quantities map[string]float64, today time.Time, weeks int, colors []int) []string {
+	if colors == nil {
+		colors = tuiHeatmapColors["shibafu"]
+	}
+	max := 0.0
+	for _, q := range quantities {
+		if q > max {
+			max = q
+		}
+	}
+
+	start := today.AddDate(0, 0, -int(today.Weekday())-7*(weeks-1))
+	labels := []string{"   ", "Mon", "   ", "Wed", "   ", "Fri", "   "}
+	lines := make([]string, 7)
+	for wd := 0; wd < 7; wd++ {
+		var b strings.Builder
+		b.WriteString(labels[wd] + " ")
+		for w := 0; w < weeks; w++ {
+			d := start.AddDate(0, 0, 7*w+wd)
+			if d.After(today) {
+				break
+			}
+			color := tuiEmptyPixelBg
+			if q := quantities[d.Format(pixelDateLayout)]; q > 0 && max > 0 {
+				level := int(math.Ceil(q/max*tuiHeatmapLevels)) - 1
+				color = colors[level]
+			}
+			fmt.Fprintf(&b, "\x1b[38;5;%dm■%s ", color, tuiResetColor)
+		}
+		lines[wd] = strings.TrimRight(b.String(), " ")
+	}
+	return lines
+}
+
+func padRight(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		r := []rune(s)
+		return string(r[:width])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// readTUIKey reads a key press in raw mode and names special keys.
+func readTUIKey(r *bufio.Reader) (string, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch c {
+	case 0x1b:
+		if r.Buffered() < 2 {
+			return "esc", nil
+		}
+		seq := make([]byte, 2)
+		if _, err := io.ReadFull(r, seq); err != nil {
+			return "", err
+		}
+		switch string(seq) {
+		case "[A":
+			return "up", nil
+		case "[B":
+			return "down", nil
+		case "[C":
+			return "right", nil
+		case "[D":
+			return "left", nil
+		}
+		return "esc", nil
+	case '\r', '\n':
+		return "enter", nil
+	case 0x7f, 0x08:
+		return "backspace", nil
+	case 0x03:
+		return "ctrl-c", nil
+	}
+	if err := r.UnreadByte(); err != nil {
+		return "", err
+	}
+	ch, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	return string(ch), nil
+}
+
+// makeRawTerminal switches the terminal into raw mode by stty, and returns the function restoring it.
+func makeRawTerminal(tty *os.File) (func(), error) {
+	state, err := stty(tty, "-g")
+	if err != nil {
+		return nil, err
+	}
+	_, err = stty(tty, "raw", "-echo")
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		stty(tty, strings.TrimSpace(state))
+	}, nil
+}
+
+func terminalSize(tty *os.File) (int, int) {
+	out, err := stty(tty, "size")
+	if err == nil {
+		fields := strings.Fields(out)
+		if len(fields) == 2 {
+			rows, errRows := strconv.Atoi(fields[0])
+			cols, errCols := strconv.Atoi(fields[1])
+			if errRows == nil && errCols == nil && rows > 0 && cols > 0 {
+				return cols, rows
+			}
+		}
+	}
+	return 80, 24
+}
+
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("Failed to run stty : %s", err)
+	}
+	return string(out), nil
+}
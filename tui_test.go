@@ -0,0 +1,138 @@
+package pi
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestTUI(t *testing.T, requested *[]string) *tui {
+	tu := newTUI("c-know")
+	tu.now = func() time.Time { return time.Date(2019, 1, 10, 12, 0, 0, 0, time.UTC) }
+	tu.fetchGraphs = func(username string) ([]graphDefinition, error) {
+		return []graphDefinition{
+			{ID: "first", Name: "First", Unit: "commits", Type: "int", Color: "shibafu"},
+			{ID: "second", Name: "Second", Unit: "km", Type: "float", Color: "sora"},
+		}, nil
+	}
+	tu.fetchPixels = func(username string, id string, from string, to string) ([]pixel, error) {
+		if from != "20180823" || to != "20190110" {
+			t.Errorf("Unexpected period. %s - %s", from, to)
+		}
+		return []pixel{{Date: "20190109", Quantity: "2"}, {Date: "20190110", Quantity: "4", OptionalData: `{"a":1}`}}, nil
+	}
+	tu.do = func(req *http.Request) ([]byte, error) {
+		body := ""
+		if req.Body != nil {
+			b, _ := ioutil.ReadAll(req.Body)
+			body = string(b)
+		}
+		*requested = append(*requested, strings.TrimSpace(fmt.Sprintf("%s %s %s", req.Method, req.URL.Path, body)))
+		return []byte(`{"message":"Success.","isSuccess":true}`), nil
+	}
+	tu.reloadGraphs()
+	return tu
+}
+
+func typeTUIKeys(tu *tui, keys ...string) {
+	for _, k := range keys {
+		if len(k) > 1 && k != "enter" && k != "esc" && k != "backspace" && k != "down" && k != "up" {
+			for _, r := range k {
+				tu.handleKey(string(r))
+			}
+			continue
+		}
+		tu.handleKey(k)
+	}
+}
+
+func TestTUIOperations(t *testing.T) {
+	beforeAPIBaseEnv, beforeTokenEnv, _, _ := prepare()
+	defer cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	requested := []string{}
+	tu := newTestTUI(t, &requested)
+
+	typeTUIKeys(tu, "down", "p", "backspace", "8", "enter", "1.5", "enter")
+	typeTUIKeys(tu, "up", "i", "x")
+	typeTUIKeys(tu, "d", "enter", "n", "enter")
+	typeTUIKeys(tu, "d", "enter", "y", "enter")
+	typeTUIKeys(tu, "u", "esc")
+	typeTUIKeys(tu, "e", "enter", "enter", "backspace", "backspace", "backspace", "backspace", "backspace", "backspace", "backspace", "momiji", "enter")
+
+	want := []string{
+		`POST /v1/users/c-know/graphs/second {"date":"20190118","quantity":"1.5"}`,
+		`PUT /v1/users/c-know/graphs/first/increment`,
+		`PUT /v1/users/c-know/graphs/first/decrement`,
+		`DELETE /v1/users/c-know/graphs/first/20190110`,
+		`PUT /v1/users/c-know/graphs/first {"color":"momiji"}`,
+	}
+	if strings.Join(requested, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected requests.\n%s", strings.Join(requested, "\n"))
+	}
+
+	typeTUIKeys(tu, "e", "enter", "enter", "backspace", "backspace", "backspace", "backspace", "backspace", "backspace", "pink", "enter")
+	if !strings.Contains(tu.message, "color must be one of") {
+		t.Errorf("Unexpected message. %s", tu.message)
+	}
+	if len(requested) != len(want) {
+		t.Errorf("invalid color should not be requested. %v", requested)
+	}
+
+	if tu.handleKey("q") {
+		t.Errorf("q should quit")
+	}
+}
+
+func TestTUIRender(t *testing.T) {
+	requested := []string{}
+	tu := newTestTUI(t, &requested)
+	tu.handleKey("p")
+
+	lines := tu.render(100, 30)
+	if len(lines) != 30 {
+		t.Errorf("Unexpected number of lines. %d", len(lines))
+	}
+	screen := strings.Join(lines, "\n")
+	for _, want := range []string{"> first", "  second", "First (first)", "unit: commits", "20190110           4  {\"a\":1}", "date: 20190110_"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("Screen does not contain %q.\n%s", want, screen)
+		}
+	}
+}
+
+func TestRenderHeatmap(t *testing.T) {
+	today := time.Date(2019, 1, 9, 0, 0, 0, 0, time.UTC) // Wednesday
+	lines := renderHeatmap(map[string]float64{"20190109": 4, "20190106": 1}, today, 2, nil)
+	if len(lines) != 7 {
+		t.Fatalf("Unexpected number of rows. %d", len(lines))
+	}
+	// the first column starts on Sunday 20181230.
+	if strings.Count(lines[0], "■") != 2 || strings.Count(lines[3], "■") != 2 || strings.Count(lines[4], "■") != 1 {
+		t.Errorf("Unexpected cells.\n%s", strings.Join(lines, "\n"))
+	}
+	if !strings.HasSuffix(lines[0], "\x1b[38;5;22m■\x1b[0m") {
+		t.Errorf("Unexpected color of the lowest level. %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[3], "\x1b[38;5;46m■\x1b[0m") {
+		t.Errorf("Unexpected color of the highest level. %q", lines[3])
+	}
+}
+
+func TestReadTUIKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\x1b[A\x1b[Bq\r\x7fあ\x03"))
+	want := []string{"up", "down", "q", "enter", "backspace", "あ", "ctrl-c"}
+	for _, w := range want {
+		got, err := readTUIKey(r)
+		if err != nil {
+			t.Fatalf("Unexpected error occurs. %s", err)
+		}
+		if got != w {
+			t.Errorf("key=%q want=%q", got, w)
+		}
+	}
+}
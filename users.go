@@ -3,12 +3,19 @@ package pi
 import (
 	"fmt"
 	"net/http"
+	"os"
 )
 
 type usersCommand struct {
-	Create createUserCommand `description:"create User" command:"create" subcommands-optional:"true"`
-	Update updateUserCommand `description:"update User Token" command:"update" subcommands-optional:"true"`
-	Delete deleteUserCommand `description:"delete User" command:"delete" subcommands-optional:"true"`
+	Create  createUserCommand  `description:"create User" command:"create" subcommands-optional:"true"`
+	Update  updateUserCommand  `description:"update User Token" command:"update" subcommands-optional:"true"`
+	Delete  deleteUserCommand  `description:"delete User" command:"delete" subcommands-optional:"true"`
+	Profile userProfileCommand `description:"operate User profile page" command:"profile" subcommands-optional:"true"`
+}
+
+type userProfileCommand struct {
+	Update updateUserProfileCommand `description:"update User profile" command:"update" subcommands-optional:"true"`
+	URL    userProfileURLCommand    `description:"get User profile page URL" command:"url" subcommands-optional:"true"`
 }
 
 type createUserCommand struct {
@@ -42,6 +49,31 @@ type deleteUserCommand struct {
 	Username string `short:"u" long:"username" description:"User name to be deleted."`
 }
 
+type updateUserProfileCommand struct {
+	Username          string   `short:"u" long:"username" description:"User name to be updated."`
+	DisplayName       string   `short:"n" long:"display-name" description:"User's display name."`
+	GravatarIconEmail string   `short:"e" long:"gravatar-icon-email" description:"The email address registered with Gravatar. The icon is displayed on the profile page."`
+	Title             string   `short:"t" long:"title" description:"The title of the user."`
+	Timezone          string   `short:"z" long:"timezone" description:"The timezone of the user."`
+	AboutURL          string   `short:"a" long:"about-url" description:"The URL of a page introducing the user."`
+	ContributeURLs    []string `short:"c" long:"contribute-urls" description:"The URLs of the user's activities. Multiple params can be specified."`
+	PinnedGraphID     string   `short:"p" long:"pinned-graph-id" description:"ID of the graph pinned on the profile page."`
+}
+
+type updateUserProfileParams struct {
+	DisplayName       string   `json:"displayName,omitempty"`
+	GravatarIconEmail string   `json:"gravatarIconEmail,omitempty"`
+	Title             string   `json:"title,omitempty"`
+	Timezone          string   `json:"timezone,omitempty"`
+	AboutURL          string   `json:"aboutURL,omitempty"`
+	ContributeURLs    []string `json:"contributeURLs,omitempty"`
+	PinnedGraphID     string   `json:"pinnedGraphID,omitempty"`
+}
+
+type userProfileURLCommand struct {
+	Username string `short:"u" long:"username" description:"User name of the profile page."`
+}
+
 func (cC *createUserCommand) Execute(args []string) error {
 
 	req, err := generateCreateUserRequest(cC)
@@ -131,3 +163,54 @@ func generateDeleteUserRequest(dC *deleteUserCommand) (*http.Request, error) {
 	}
 	return req, nil
 }
+
+func (uP *updateUserProfileCommand) Execute(args []string) error {
+	req, err := generateUpdateUserProfileRequest(uP)
+	if err != nil {
+		return err
+	}
+
+	err = doRequest(req)
+	return err
+}
+
+func generateUpdateUserProfileRequest(uP *updateUserProfileCommand) (*http.Request, error) {
+	username, err := getUsername(uP.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	paramStruct := &updateUserProfileParams{
+		DisplayName:       uP.DisplayName,
+		GravatarIconEmail: uP.GravatarIconEmail,
+		Title:             uP.Title,
+		Timezone:          uP.Timezone,
+		AboutURL:          uP.AboutURL,
+		ContributeURLs:    uP.ContributeURLs,
+		PinnedGraphID:     uP.PinnedGraphID,
+	}
+
+	req, err := generateRequestWithToken(
+		"PUT",
+		fmt.Sprintf("@%s", username),
+		paramStruct,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate update api request : %s", err)
+	}
+	return req, nil
+}
+
+func (uP *userProfileURLCommand) Execute(args []string) error {
+	username, err := getUsername(uP.Username)
+	if err != nil {
+		return err
+	}
+
+	apibase := os.Getenv("PIXELA_API_BASE")
+	if apibase == "" {
+		apibase = "pixe.la"
+	}
+	fmt.Printf("https://%s/@%s", apibase, username)
+	return nil
+}
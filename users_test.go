@@ -56,6 +56,16 @@ var userTests = []struct {
 		input:    []string{"users", "delete"},
 		exitCode: 1,
 	},
+	{
+		name:     "update user profile - not specify username",
+		input:    []string{"users", "profile", "update", "--display-name", "c-know"},
+		exitCode: 1,
+	},
+	{
+		name:     "user profile url - not specify username",
+		input:    []string{"users", "profile", "url"},
+		exitCode: 1,
+	},
 }
 
 func TestUser(t *testing.T) {
@@ -234,3 +244,86 @@ func TestGenerateDeleteUserRequest(t *testing.T) {
 		t.Errorf("Unexpected request header. %s", req.Header.Get("X-USER-TOKEN"))
 	}
 }
+
+func TestGenerateUpdateUserProfileRequest(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, afterAPIBaseEnv, afterTokenEnv := prepare()
+
+	testUsername := "c-know"
+	testDisplayName := "c-know"
+	testGravatarIconEmail := "c-know@example.com"
+	testTitle := "Pixela user"
+	testTimezone := "Asia/Tokyo"
+	testAboutURL := "https://example.com/about"
+	testContributeURLs := []string{"https://example.com/a", "https://example.com/b"}
+	testPinnedGraphID := "test-id"
+	cmd := &updateUserProfileCommand{
+		Username:          testUsername,
+		DisplayName:       testDisplayName,
+		GravatarIconEmail: testGravatarIconEmail,
+		Title:             testTitle,
+		Timezone:          testTimezone,
+		AboutURL:          testAboutURL,
+		ContributeURLs:    testContributeURLs,
+		PinnedGraphID:     testPinnedGraphID,
+	}
+
+	// run
+	req, err := generateUpdateUserProfileRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	if req.Method != "PUT" {
+		t.Errorf("Unexpected request method. %s", req.Method)
+	}
+	if req.URL.String() != fmt.Sprintf("https://%s/@%s", afterAPIBaseEnv, testUsername) {
+		t.Errorf("Unexpected request path. %s", req.URL.String())
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		t.Errorf("Failed to read request body. %s", err)
+	}
+	if string(b) != fmt.Sprintf(`{"displayName":"%s","gravatarIconEmail":"%s","title":"%s","timezone":"%s","aboutURL":"%s","contributeURLs":["%s","%s"],"pinnedGraphID":"%s"}`, testDisplayName, testGravatarIconEmail, testTitle, testTimezone, testAboutURL, testContributeURLs[0], testContributeURLs[1], testPinnedGraphID) {
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+	if req.Header.Get("X-USER-TOKEN") != afterTokenEnv {
+		t.Errorf("Unexpected request header. %s", req.Header.Get("X-USER-TOKEN"))
+	}
+}
+
+func TestGenerateUpdateUserProfileRequestWithSomeParams(t *testing.T) {
+	// prepare
+	beforeAPIBaseEnv, beforeTokenEnv, _, _ := prepare()
+
+	testUsername := "c-know"
+	testPinnedGraphID := "test-id"
+	cmd := &updateUserProfileCommand{
+		Username:      testUsername,
+		PinnedGraphID: testPinnedGraphID,
+	}
+
+	// run
+	req, err := generateUpdateUserProfileRequest(cmd)
+
+	// cleanup
+	cleanup(beforeAPIBaseEnv, beforeTokenEnv)
+
+	// assertion
+	if err != nil {
+		t.Errorf("Unexpected error occurs. %s", err)
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	defer req.Body.Close()
+	if err != nil {
+		t.Errorf("Failed to read request body. %s", err)
+	}
+	if string(b) != fmt.Sprintf(`{"pinnedGraphID":"%s"}`, testPinnedGraphID) {
+		t.Errorf("Unexpected request body. %s", string(b))
+	}
+}